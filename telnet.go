@@ -1,11 +1,14 @@
 package zencached
 
 import (
+	"bufio"
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"net"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/uol/logh"
@@ -31,6 +34,19 @@ type Node struct {
 
 	// Port - the server's port
 	Port int
+
+	// Weight - the server's relative share of keys (zero is the same as one)
+	Weight int
+}
+
+// weight - returns the node's weight, defaulting to one
+func (n *Node) weight() int {
+
+	if n.Weight <= 0 {
+		return 1
+	}
+
+	return n.Weight
 }
 
 // TelnetConfiguration - contains the telnet connection configuration
@@ -56,9 +72,20 @@ type TelnetConfiguration struct {
 type Telnet struct {
 	address       *net.TCPAddr
 	connection    *net.TCPConn
+	reader        *bufio.Reader
 	logger        *logh.ContextualLogger
 	configuration *TelnetConfiguration
 	node          *Node
+	connMutex     sync.Mutex
+	ctx           context.Context
+	stopWatcher   chan struct{}
+	watcherDone   chan struct{}
+	broken        bool
+	failed        bool
+	sent          bool
+	probe         bool
+	breaker       *circuitBreaker
+	pool          *nodePool
 }
 
 // NewTelnet - creates a new telnet connection
@@ -84,7 +111,7 @@ func NewTelnet(node *Node, configuration *TelnetConfiguration) (*Telnet, error)
 // resolveServerAddress - configures the server address
 func (t *Telnet) resolveServerAddress() error {
 
-	hostPort := fmt.Sprintf("%s:%d", t.node.Host, t.node.Port)
+	hostPort := nodeAddress(t.node)
 
 	if logh.DebugEnabled {
 		t.logger.Debug().Msgf("resolving address: %s", hostPort)
@@ -122,11 +149,19 @@ func (t *Telnet) Connect() error {
 	return nil
 }
 
-// dial - connects the telnet client
+// dial - connects the telnet client, limited by the max write timeout and the bound context
 func (t *Telnet) dial() error {
 
-	var err error
-	t.connection, err = net.DialTCP("tcp", nil, t.address)
+	ctx := t.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	dialer := net.Dialer{
+		Timeout: t.configuration.MaxWriteTimeout,
+	}
+
+	conn, err := dialer.DialContext(ctx, "tcp", t.address.String())
 	if err != nil {
 		if logh.ErrorEnabled {
 			t.logger.Error().Err(err).Msgf("error connecting to address: %s", t.address.String())
@@ -134,7 +169,9 @@ func (t *Telnet) dial() error {
 		return err
 	}
 
-	err = t.connection.SetDeadline(time.Time{})
+	connection := conn.(*net.TCPConn)
+
+	err = connection.SetDeadline(time.Time{})
 	if err != nil {
 		if logh.ErrorEnabled {
 			t.logger.Error().Err(err).Msg("error setting connection's deadline")
@@ -142,12 +179,20 @@ func (t *Telnet) dial() error {
 		return err
 	}
 
+	t.connMutex.Lock()
+	t.connection = connection
+	t.connMutex.Unlock()
+
+	t.reader = bufio.NewReaderSize(t.connection, t.configuration.ReadBufferSize)
+
 	return nil
 }
 
 // Close - closes the active connection
 func (t *Telnet) Close() {
 
+	t.broken = false
+
 	if t.connection == nil {
 		return
 	}
@@ -163,20 +208,217 @@ func (t *Telnet) Close() {
 		t.logger.Info().Msg("connection closed")
 	}
 
+	t.connMutex.Lock()
 	t.connection = nil
+	t.connMutex.Unlock()
+
+	t.reader = nil
+}
+
+// bindContext - binds the connection to the context of an operation, the context deadline limits the
+// read and write deadlines and its cancellation aborts any pending read or write
+func (t *Telnet) bindContext(ctx context.Context) {
+
+	t.ctx = ctx
+
+	if ctx.Done() == nil {
+		return
+	}
+
+	t.stopWatcher = make(chan struct{})
+	t.watcherDone = make(chan struct{})
+
+	go t.watchContext(ctx, t.stopWatcher, t.watcherDone)
+}
+
+// watchContext - aborts the pending operation when the context is done
+func (t *Telnet) watchContext(ctx context.Context, stopWatcher, watcherDone chan struct{}) {
+
+	defer close(watcherDone)
+
+	select {
+	case <-ctx.Done():
+	case <-stopWatcher:
+		return
+	}
+
+	t.connMutex.Lock()
+	defer t.connMutex.Unlock()
+
+	if t.connection == nil {
+		return
+	}
+
+	err := t.connection.SetDeadline(time.Now())
+	if err != nil {
+		if logh.ErrorEnabled {
+			t.logger.Error().Err(err).Msg("error aborting the connection operation")
+		}
+	}
+}
+
+// unbindContext - releases the operation context, returns true if the context was done
+func (t *Telnet) unbindContext() bool {
+
+	if t.ctx == nil {
+		return false
+	}
+
+	if t.stopWatcher != nil {
+		close(t.stopWatcher)
+		<-t.watcherDone
+		t.stopWatcher = nil
+		t.watcherDone = nil
+	}
+
+	done := t.ctx.Err() != nil
+	t.ctx = nil
+
+	return done
+}
+
+// contextDone - returns the done channel of the bound context (nil blocks forever)
+func (t *Telnet) contextDone() <-chan struct{} {
+
+	if t.ctx == nil {
+		return nil
+	}
+
+	return t.ctx.Done()
+}
+
+// contextError - returns the bound context error, if any
+func (t *Telnet) contextError() error {
+
+	if t.ctx == nil {
+		return nil
+	}
+
+	return t.ctx.Err()
+}
+
+// markBroken - marks the connection as broken, it may have unread data from a failed operation
+func (t *Telnet) markBroken() {
+
+	t.broken = true
+}
+
+// markFailed - marks the connection as broken by a transport error or timeout, the node failure signal
+func (t *Telnet) markFailed() {
+
+	t.broken = true
+	t.failed = true
+}
+
+// IsBroken - checks if the connection must be discarded (closed connections are reconnected on the next send)
+func (t *Telnet) IsBroken() bool {
+
+	return t.broken
+}
+
+// resetSent - returns if a command was sent since the last reset
+func (t *Telnet) resetSent() bool {
+
+	sent := t.sent
+	t.sent = false
+
+	return sent
+}
+
+// resetFailed - returns if a transport error or timeout happened since the last reset
+func (t *Telnet) resetFailed() bool {
+
+	failed := t.failed
+	t.failed = false
+
+	return failed
+}
+
+// operationError - marks the connection as failed and replaces the connection error by the context error when the bound context is done
+func (t *Telnet) operationError(err error) error {
+
+	t.markFailed()
+
+	if ctxErr := t.contextError(); ctxErr != nil {
+		return ctxErr
+	}
+
+	// the socket deadline may expire slightly before the context one
+	if castedErr, ok := err.(net.Error); ok && castedErr.Timeout() && t.ctx != nil {
+		if ctxDeadline, ok := t.ctx.Deadline(); ok && !time.Now().Before(ctxDeadline) {
+			return context.DeadlineExceeded
+		}
+	}
+
+	return err
+}
+
+// setDeadline - sets the read or write deadline of the active connection, limited by the bound context deadline
+func (t *Telnet) setDeadline(op operation) error {
+
+	t.connMutex.Lock()
+	defer t.connMutex.Unlock()
+
+	if t.connection == nil {
+		return fmt.Errorf("connection is not established")
+	}
+
+	// checked under the lock to not override the deadline set by the context watcher
+	if err := t.contextError(); err != nil {
+		return err
+	}
+
+	timeout := t.configuration.MaxReadTimeout
+	if op == write {
+		timeout = t.configuration.MaxWriteTimeout
+	}
+
+	deadline := time.Now().Add(timeout)
+	if t.ctx != nil {
+		if ctxDeadline, ok := t.ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+			deadline = ctxDeadline
+		}
+	}
+
+	var err error
+	if op == read {
+		err = t.connection.SetReadDeadline(deadline)
+	} else {
+		err = t.connection.SetWriteDeadline(deadline)
+	}
+
+	if err != nil {
+		if logh.ErrorEnabled {
+			t.logger.Error().Msg(fmt.Sprintf("error setting %s deadline: %s", op, err.Error()))
+		}
+		return err
+	}
+
+	return nil
 }
 
 // Send - send some command to the server
 func (t *Telnet) Send(command ...[]byte) error {
 
+	t.sent = true
+
 	var err error
 	for _, c := range command {
 		for i := 0; i < t.configuration.MaxWriteRetries; i++ {
 			if !t.writePayload(c) {
+				if ctxErr := t.contextError(); ctxErr != nil {
+					t.markBroken()
+					return ctxErr
+				}
 				t.Close()
 				err = t.Connect()
 				if err != nil {
-					<-time.After(t.configuration.ReconnectionTimeout)
+					select {
+					case <-time.After(t.configuration.ReconnectionTimeout):
+					case <-t.contextDone():
+						t.markBroken()
+						return t.contextError()
+					}
 					continue
 				}
 			} else {
@@ -185,43 +427,36 @@ func (t *Telnet) Send(command ...[]byte) error {
 		}
 	}
 
+	if err != nil {
+		t.markFailed()
+	}
+
 	return err
 }
 
-// Read - reads the payload from the active connection
+// Read - reads full lines from the active connection until a line containing one of the inputs is found
 func (t *Telnet) Read(endConnInput [][]byte) ([]byte, error) {
 
-	err := t.connection.SetReadDeadline(time.Now().Add(t.configuration.MaxReadTimeout))
+	err := t.setDeadline(read)
 	if err != nil {
-		if logh.ErrorEnabled {
-			t.logger.Error().Msg(fmt.Sprintf("error setting read deadline: %s", err.Error()))
-		}
-		return nil, err
+		return nil, t.operationError(err)
 	}
 
 	fullBuffer := bytes.Buffer{}
 	fullBuffer.Grow(t.configuration.ReadBufferSize)
 
-	buffer := make([]byte, t.configuration.ReadBufferSize)
-	var bytesRead, fullBytes int
-	growSize := 1
+	var line []byte
 
 mainLoop:
 	for {
-		bytesRead, err = t.connection.Read(buffer)
-		if bytesRead == 0 || err != nil {
+		line, err = t.reader.ReadBytes(lineBreaksN)
+		fullBuffer.Write(line)
+		if err != nil {
 			break mainLoop
 		}
 
-		fullBytes += bytesRead
-		if fullBytes > t.configuration.ReadBufferSize*growSize {
-			fullBuffer.Grow(t.configuration.ReadBufferSize)
-		}
-
-		fullBuffer.Write((buffer[0:bytesRead]))
-
 		for j := 0; j < len(endConnInput); j++ {
-			if bytes.LastIndex(buffer[0:bytesRead], endConnInput[j]) != -1 {
+			if bytes.Contains(line, endConnInput[j]) {
 				break mainLoop
 			}
 		}
@@ -229,24 +464,58 @@ mainLoop:
 
 	if err != nil && err != io.EOF {
 		t.logConnectionError(err, read)
-		return nil, err
+		return nil, t.operationError(err)
 	}
 
 	return fullBuffer.Bytes(), nil
 }
 
-// writePayload - writes the payload
-func (t *Telnet) writePayload(payload []byte) bool {
+// ReadLine - reads a single line from the active connection, without the line terminator
+func (t *Telnet) ReadLine() ([]byte, error) {
 
-	if t.connection == nil {
-		return false
+	err := t.setDeadline(read)
+	if err != nil {
+		return nil, t.operationError(err)
 	}
 
-	err := t.connection.SetWriteDeadline(time.Now().Add(t.configuration.MaxWriteTimeout))
+	line, err := t.reader.ReadBytes(lineBreaksN)
+	if err != nil {
+		t.logConnectionError(err, read)
+		return nil, t.operationError(err)
+	}
+
+	if !bytes.HasSuffix(line, doubleBreaks) {
+		t.markBroken()
+		return nil, fmt.Errorf("malformed response line: %q", line)
+	}
+
+	return line[:len(line)-len(doubleBreaks)], nil
+}
+
+// ReadFull - reads exactly the specified number of bytes from the active connection
+func (t *Telnet) ReadFull(size int) ([]byte, error) {
+
+	err := t.setDeadline(read)
+	if err != nil {
+		return nil, t.operationError(err)
+	}
+
+	payload := make([]byte, size)
+
+	_, err = io.ReadFull(t.reader, payload)
+	if err != nil {
+		t.logConnectionError(err, read)
+		return nil, t.operationError(err)
+	}
+
+	return payload, nil
+}
+
+// writePayload - writes the payload
+func (t *Telnet) writePayload(payload []byte) bool {
+
+	err := t.setDeadline(write)
 	if err != nil {
-		if logh.ErrorEnabled {
-			t.logger.Error().Err(err).Msg("error setting write deadline")
-		}
 		return false
 	}
 
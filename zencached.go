@@ -1,7 +1,10 @@
 package zencached
 
 import (
-	"math/rand"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -17,59 +20,147 @@ import (
 type Configuration struct {
 	Nodes                 []Node
 	NumConnectionsPerNode int
+	RoutingAlgorithm      RoutingAlgorithm
+	Router                Router
+	// RouterFactory - creates the custom router of each node set, required to change the nodes of a custom routing at runtime
+	RouterFactory func() Router
+	Protocol      Protocol
+	// ConnectionAcquireTimeout - the max time to wait for an idle node connection, zero waits forever
+	ConnectionAcquireTimeout time.Duration
+	// HealthCheckInterval - the interval to check the idle connections, zero disables the health checker
+	HealthCheckInterval time.Duration
+	// EagerConnect - dials all connections and verifies each node when creating the instance
+	EagerConnect bool
+	// StartupPolicy - what to do when a node fails the eager connection
+	StartupPolicy StartupPolicy
+	// EjectionThreshold - the number of consecutive failures to eject a node from the routing, zero disables the ejection
+	EjectionThreshold int
+	// EjectionProbeInterval - the interval to probe the ejected nodes to readmit them (default one second)
+	EjectionProbeInterval time.Duration
+	// CircuitBreakerThreshold - the number of consecutive failures to open the node's circuit, zero disables the circuit breaker
+	CircuitBreakerThreshold int
+	// CircuitBreakerOpenTimeout - the time the circuit stays open before a trial operation (default one second)
+	CircuitBreakerOpenTimeout time.Duration
+	// ClusterOperationTimeout - the overall deadline of the operations sent to all nodes, zero uses only the context deadline
+	ClusterOperationTimeout time.Duration
+	// ClusterReadRepair - adds the value found by the cluster get to the nodes missing it
+	ClusterReadRepair bool
+	// ClusterReadRepairTTL - the ttl of the repaired values, required by the read repair (from one second up to 30 days)
+	ClusterReadRepairTTL time.Duration
+	// ReplicationFactor - the number of nodes storing each key on the replicated operations (default one), limited by the number of nodes
+	ReplicationFactor int
+	// WriteQuorum - the replicas required to answer a replicated write (default the majority of the replicas)
+	WriteQuorum int
+	// ReadQuorum - the replicas required to answer a replicated read (default one)
+	ReadQuorum int
+	// Discoverer - discovers the nodes on startup (the configured nodes are used if it fails), nil disables the discovery
+	Discoverer Discoverer
+	// DiscoveryInterval - the interval to refresh the discovered nodes, zero disables the refresh
+	DiscoveryInterval time.Duration
 	TelnetConfiguration
 }
 
+// ErrPoolExhausted - returned when no idle node connection is available within the acquisition timeout
+var ErrPoolExhausted error = errors.New("connection pool exhausted, no idle connection available")
+
 // Zencached - declares the main structure
 type Zencached struct {
-	nodeTelnetConns    []chan *Telnet
-	numNodeTelnetConns int
-	configuration      *Configuration
-	logger             *logh.ContextualLogger
-	shuttingDown       uint32
-	metricsCollector   MetricsCollector
-	enableMetrics      bool
+	configuration    *Configuration
+	logger           *logh.ContextualLogger
+	shuttingDown     uint32
+	metricsCollector MetricsCollector
+	enableMetrics    bool
+	nodes            atomic.Value
+	nodesMutex       sync.Mutex
+	backgroundStop   chan struct{}
+	backgroundTasks  sync.WaitGroup
+	failover         atomic.Value
+	startupErr       *StartupError
 }
 
-// New - creates a new instance
+// New - creates a new instance (with the StartDegraded policy, the failed nodes are returned by StartupError)
 func New(configuration *Configuration, metricsCollector MetricsCollector) (*Zencached, error) {
 
-	numNodes := len(configuration.Nodes)
-	nodeTelnetConns := make([]chan *Telnet, numNodes)
+	if configuration.ClusterReadRepair && (configuration.ClusterReadRepairTTL < time.Second || configuration.ClusterReadRepairTTL > maxReadRepairTTL) {
+		return nil, fmt.Errorf("the read repair ttl must be from one second up to 30 days: %s", configuration.ClusterReadRepairTTL)
+	}
 
-	for i := 0; i < numNodes; i++ {
+	enableMetrics := metricsCollector != nil
 
-		channel := make(chan *Telnet, configuration.NumConnectionsPerNode)
+	z := &Zencached{
+		configuration:    configuration,
+		logger:           logh.CreateContextualLogger("pkg", "zencached"),
+		metricsCollector: metricsCollector,
+		enableMetrics:    enableMetrics,
+		backgroundStop:   make(chan struct{}),
+	}
 
-		for c := 0; c < configuration.NumConnectionsPerNode; c++ {
+	nodes := configuration.Nodes
 
-			telnetConn, err := NewTelnet(&configuration.Nodes[i], &configuration.TelnetConfiguration)
-			if err != nil {
+	if configuration.Discoverer != nil {
+		discovered, err := z.discoverNodes()
+		if err != nil {
+			if len(nodes) == 0 {
 				return nil, err
 			}
 
-			channel <- telnetConn
+			if logh.WarnEnabled {
+				z.logger.Warn().Err(err).Msg("error discovering the nodes, using the configured ones")
+			}
+		} else {
+			nodes = discovered
 		}
+	}
 
-		nodeTelnetConns[i] = channel
+	err := configuration.checkReplication(len(nodes))
+	if err != nil {
+		return nil, err
 	}
 
-	enableMetrics := metricsCollector != nil
+	err = configuration.checkRouter()
+	if err != nil {
+		return nil, err
+	}
+
+	pools := make([]*nodePool, len(nodes))
+
+	for i := 0; i < len(nodes); i++ {
+
+		pools[i], err = z.newNodePool(nodes[i])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	z.nodes.Store(z.newNodeSet(nodes, pools))
+	z.storeFailover(z.loadNodes())
+
+	if configuration.EagerConnect {
+		startupErr := z.connectAll()
+		if startupErr != nil {
+			if configuration.StartupPolicy == FailFast {
+				z.Shutdown()
+				return nil, startupErr
+			}
+
+			z.startupErr = startupErr
+		}
+	}
 
-	return &Zencached{
-		nodeTelnetConns:    nodeTelnetConns,
-		numNodeTelnetConns: numNodes,
-		configuration:      configuration,
-		logger:             logh.CreateContextualLogger("pkg", "zencached"),
-		metricsCollector:   metricsCollector,
-		enableMetrics:      enableMetrics,
-	}, nil
+	z.startBackgroundTasks()
+
+	return z, nil
 }
 
 // Shutdown - closes all connections
 func (z *Zencached) Shutdown() {
 
-	if atomic.LoadUint32(&z.shuttingDown) == 1 {
+	// no node changes are accepted after this point
+	z.nodesMutex.Lock()
+	swapped := atomic.CompareAndSwapUint32(&z.shuttingDown, 0, 1)
+	z.nodesMutex.Unlock()
+
+	if !swapped {
 		if logh.InfoEnabled {
 			z.logger.Info().Msg("already shutting down...")
 		}
@@ -80,9 +171,10 @@ func (z *Zencached) Shutdown() {
 		z.logger.Info().Msg("shutting down...")
 	}
 
-	atomic.SwapUint32(&z.shuttingDown, 1)
+	z.stopBackgroundTasks()
+
 	closed := 0
-	for nodeIndex, nodeConns := range z.nodeTelnetConns {
+	for nodeIndex, pool := range z.loadNodes().pools {
 
 		if logh.InfoEnabled {
 			z.logger.Info().Msgf("closing node connections from index: %d", nodeIndex)
@@ -94,7 +186,7 @@ func (z *Zencached) Shutdown() {
 				z.logger.Debug().Msg("closing connection...")
 			}
 
-			conn := <-nodeConns
+			conn := <-pool.conns
 			conn.Close()
 			closed++
 
@@ -105,17 +197,82 @@ func (z *Zencached) Shutdown() {
 	}
 }
 
-// GetTelnetConnByNodeIndex - returns a telnet connection by node index
-func (z *Zencached) GetTelnetConnByNodeIndex(index int) (telnetConn *Telnet) {
+// GetTelnetConnByNodeIndex - returns a telnet connection by node index, blocks until a connection is available
+// ignoring the acquisition timeout like GetTelnetConnection (panics if the index is out of range, use
+// GetTelnetConnByNodeIndexContext to get the errors)
+func (z *Zencached) GetTelnetConnByNodeIndex(index int) *Telnet {
+
+	telnetConn, err := z.acquireByNodeIndex(context.Background(), index, 0)
+	if err != nil {
+		panic(err)
+	}
+
+	return telnetConn
+}
+
+// GetTelnetConnByNodeIndexContext - returns a telnet connection by node index, waiting until the context is done
+// or the acquisition timeout expires (ErrPoolExhausted)
+func (z *Zencached) GetTelnetConnByNodeIndexContext(ctx context.Context, index int) (*Telnet, error) {
+
+	return z.acquireByNodeIndex(ctx, index, z.configuration.ConnectionAcquireTimeout)
+}
+
+// acquireByNodeIndex - waits for an idle connection of the node at the index, using the new node set if the node is removed
+func (z *Zencached) acquireByNodeIndex(ctx context.Context, index int, timeout time.Duration) (*Telnet, error) {
+
+	for {
+		nodes := z.loadNodes()
+		if index < 0 || index >= len(nodes.pools) {
+			return nil, fmt.Errorf("invalid node index: %d", index)
+		}
+
+		telnetConn, err := z.acquireTelnetConn(ctx, nodes.pools[index], timeout)
+		if err != ErrNodeRemoved {
+			return telnetConn, err
+		}
+	}
+}
+
+// acquireTelnetConn - waits for an idle connection of the node (a zero timeout waits forever)
+func (z *Zencached) acquireTelnetConn(ctx context.Context, pool *nodePool, timeout time.Duration) (*Telnet, error) {
 
-	if !z.enableMetrics {
+	var timeoutChannel <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		timeoutChannel = timer.C
+	}
+
+	var start time.Time
+	if z.enableMetrics {
+		start = time.Now()
+	}
+
+	var telnetConn *Telnet
+
+	select {
+	case telnetConn = <-pool.conns:
+	case <-pool.removed:
+		return nil, ErrNodeRemoved
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-timeoutChannel:
+		if z.enableMetrics {
+			z.countAcquireTimeout(pool.node.Host, time.Since(start))
+		}
+		return nil, ErrPoolExhausted
+	}
 
-		telnetConn = <-z.nodeTelnetConns[index]
+	// the node was removed while waiting, the connection belongs to the drainer
+	select {
+	case <-pool.removed:
+		pool.conns <- telnetConn
+		return nil, ErrNodeRemoved
+	default:
+	}
 
-	} else {
+	if z.enableMetrics {
 
-		start := time.Now()
-		telnetConn = <-z.nodeTelnetConns[index]
 		elapsedTime := time.Since(start)
 
 		z.metricsCollector.Count(
@@ -131,29 +288,110 @@ func (z *Zencached) GetTelnetConnByNodeIndex(index int) (telnetConn *Telnet) {
 		)
 	}
 
-	return
+	telnetConn.bindContext(ctx)
+
+	return telnetConn, nil
 }
 
-// GetTelnetConnection - returns an idle telnet connection
+// countAcquireTimeout - sends the acquisition timeout and the time waited
+func (z *Zencached) countAcquireTimeout(host string, elapsedTime time.Duration) {
+
+	z.metricsCollector.Count(
+		1,
+		metricNodeConnTimeout,
+		tagNodeName, host,
+	)
+
+	z.metricsCollector.Maximum(
+		float64(elapsedTime.Milliseconds()),
+		metricNodeConnAvailableTime,
+		tagNodeName, host,
+	)
+}
+
+// GetTelnetConnection - returns an idle telnet connection, blocks until a connection is available ignoring the
+// acquisition timeout (use GetTelnetConnectionContext to get the errors)
 func (z *Zencached) GetTelnetConnection(routerHash []byte, key []byte) (telnetConn *Telnet, index int) {
 
+	for {
+		nodes := z.loadNodes()
+		index = z.routeIndex(nodes, routerHash, key)
+
+		var err error
+		telnetConn, err = z.acquireTelnetConn(context.Background(), nodes.pools[index], 0)
+		if err != ErrNodeRemoved {
+			return
+		}
+	}
+}
+
+// GetTelnetConnectionContext - returns an idle telnet connection, waiting until the context is done
+func (z *Zencached) GetTelnetConnectionContext(ctx context.Context, routerHash []byte, key []byte) (*Telnet, int, error) {
+
+	for {
+		nodes := z.loadNodes()
+		index := z.routeIndex(nodes, routerHash, key)
+
+		telnetConn, err := z.acquireTelnetConn(ctx, nodes.pools[index], z.configuration.ConnectionAcquireTimeout)
+		if err == ErrNodeRemoved {
+			// routes again using the new node set
+			continue
+		}
+
+		if err != nil {
+			return nil, index, err
+		}
+
+		return telnetConn, index, nil
+	}
+}
+
+// routeIndex - returns the node index for the router hash or key
+func (z *Zencached) routeIndex(nodes *nodeSet, routerHash []byte, key []byte) int {
+
 	if routerHash == nil {
 		routerHash = key
 	}
 
 	if len(routerHash) == 0 {
-		index = rand.Intn(z.numNodeTelnetConns)
-	} else {
-		index = int(routerHash[len(routerHash)-1]) % z.numNodeTelnetConns
+		return weightedRandomIndex(nodes.nodes)
 	}
 
-	telnetConn = z.GetTelnetConnByNodeIndex(index)
+	index := nodes.route(routerHash)
+
+	if nodes.pools[index].isEjected() {
+		return z.failoverIndex(nodes, routerHash, index)
+	}
 
-	return
+	return index
 }
 
-// ReturnTelnetConnection - returns a telnet connection to the pool
+// ReturnTelnetConnection - returns a telnet connection to its node pool (the index is only used by connections created outside the pool)
 func (z *Zencached) ReturnTelnetConnection(telnetConn *Telnet, index int) {
 
-	z.nodeTelnetConns[index] <- telnetConn
+	pool := telnetConn.pool
+	if pool == nil {
+		pool = z.loadNodes().pools[index]
+	}
+
+	cancelled := telnetConn.unbindContext()
+	broken := telnetConn.IsBroken()
+	failed := telnetConn.resetFailed()
+
+	// only the completed operations are considered for the ejection and the circuit, a memcached error reply is not
+	// a node failure and the health probes may find connections closed by the server idle timeout (the ejected nodes
+	// prober readmits the nodes by itself)
+	if telnetConn.resetSent() && !cancelled && !telnetConn.probe {
+		z.countNodeResult(pool, !failed)
+		z.countCircuitResult(telnetConn, !failed)
+	}
+
+	telnetConn.probe = false
+
+	// the operation may have been aborted or failed leaving unread data
+	if cancelled || broken {
+		z.recycleTelnetConnection(telnetConn)
+	}
+
+	pool.conns <- telnetConn
 }
@@ -0,0 +1,349 @@
+package zencached
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strconv"
+)
+
+//
+// The memcached binary protocol codec.
+// More information here:
+// https://github.com/memcached/memcached/wiki/BinaryProtocolRevamped
+//
+
+// Protocol - the protocol used to talk with the memcached nodes
+type Protocol int
+
+const (
+	// TextProtocol - the ascii protocol (default)
+	TextProtocol Protocol = iota
+
+	// BinaryProtocol - the binary protocol (only get, storage, cas and delete operations)
+	BinaryProtocol
+)
+
+// binary protocol constants
+const (
+	binaryHeaderSize    int  = 24
+	binaryRequestMagic  byte = 0x80
+	binaryResponseMagic byte = 0x81
+	binaryStorageExtras int  = 8
+)
+
+// binaryOpcode - the binary command opcode
+type binaryOpcode byte
+
+const (
+	binaryOpGet     binaryOpcode = 0x00
+	binaryOpSet     binaryOpcode = 0x01
+	binaryOpAdd     binaryOpcode = 0x02
+	binaryOpReplace binaryOpcode = 0x03
+	binaryOpDelete  binaryOpcode = 0x04
+	binaryOpNoop    binaryOpcode = 0x0a
+	binaryOpGetKQ   binaryOpcode = 0x0d
+	binaryOpAppend  binaryOpcode = 0x0e
+	binaryOpPrepend binaryOpcode = 0x0f
+)
+
+// binaryStatus - the binary response status
+type binaryStatus uint16
+
+const (
+	binaryStatusNoError       binaryStatus = 0x0000
+	binaryStatusKeyNotFound   binaryStatus = 0x0001
+	binaryStatusKeyExists     binaryStatus = 0x0002
+	binaryStatusItemNotStored binaryStatus = 0x0005
+)
+
+// binaryStorageOpcodes - maps the storage commands to their opcodes
+var binaryStorageOpcodes map[string]binaryOpcode = map[string]binaryOpcode{
+	string(Add):     binaryOpAdd,
+	string(Set):     binaryOpSet,
+	string(Replace): binaryOpReplace,
+	string(Append):  binaryOpAppend,
+	string(Prepend): binaryOpPrepend,
+}
+
+// binaryPacket - a binary protocol request or response
+type binaryPacket struct {
+	opcode binaryOpcode
+	status binaryStatus
+	opaque uint32
+	cas    uint64
+	extras []byte
+	key    []byte
+	value  []byte
+}
+
+// isBinaryProtocol - checks if the binary protocol is configured
+func (z *Zencached) isBinaryProtocol() bool {
+
+	return z.configuration.Protocol == BinaryProtocol
+}
+
+// checkTextProtocol - returns an error if the command is used with the binary protocol
+func (z *Zencached) checkTextProtocol(cmd memcachedCommand) error {
+
+	if z.isBinaryProtocol() {
+		return fmt.Errorf("command %s is not supported by the binary protocol", cmd)
+	}
+
+	return nil
+}
+
+// renderBinaryRequest - renders the request packet
+func (z *Zencached) renderBinaryRequest(buffer *bytes.Buffer, packet *binaryPacket) {
+
+	header := make([]byte, binaryHeaderSize)
+	header[0] = binaryRequestMagic
+	header[1] = byte(packet.opcode)
+	binary.BigEndian.PutUint16(header[2:4], uint16(len(packet.key)))
+	header[4] = byte(len(packet.extras))
+	binary.BigEndian.PutUint32(header[8:12], uint32(len(packet.extras)+len(packet.key)+len(packet.value)))
+	binary.BigEndian.PutUint32(header[12:16], packet.opaque)
+	binary.BigEndian.PutUint64(header[16:24], packet.cas)
+
+	buffer.Grow(binaryHeaderSize + len(packet.extras) + len(packet.key) + len(packet.value))
+	buffer.Write(header)
+	buffer.Write(packet.extras)
+	buffer.Write(packet.key)
+	buffer.Write(packet.value)
+}
+
+// readBinaryResponse - reads a response packet
+func (z *Zencached) readBinaryResponse(telnetConn *Telnet) (*binaryPacket, error) {
+
+	header, err := telnetConn.ReadFull(binaryHeaderSize)
+	if err != nil {
+		return nil, err
+	}
+
+	if header[0] != binaryResponseMagic {
+		telnetConn.markBroken()
+		return nil, fmt.Errorf("invalid binary response magic: 0x%02x", header[0])
+	}
+
+	keyLength := int(binary.BigEndian.Uint16(header[2:4]))
+	extrasLength := int(header[4])
+	bodyLength := int(binary.BigEndian.Uint32(header[8:12]))
+
+	if keyLength+extrasLength > bodyLength {
+		telnetConn.markBroken()
+		return nil, fmt.Errorf("invalid binary response body length: %d", bodyLength)
+	}
+
+	body, err := telnetConn.ReadFull(bodyLength)
+	if err != nil {
+		return nil, err
+	}
+
+	return &binaryPacket{
+		opcode: binaryOpcode(header[1]),
+		status: binaryStatus(binary.BigEndian.Uint16(header[6:8])),
+		opaque: binary.BigEndian.Uint32(header[12:16]),
+		cas:    binary.BigEndian.Uint64(header[16:24]),
+		extras: body[:extrasLength],
+		key:    body[extrasLength : extrasLength+keyLength],
+		value:  body[extrasLength+keyLength:],
+	}, nil
+}
+
+// executeBinary - sends a single request and reads its response
+func (z *Zencached) executeBinary(telnetConn *Telnet, cmd memcachedCommand, request *binaryPacket) (*binaryPacket, error) {
+
+	buffer := bytes.Buffer{}
+	z.renderBinaryRequest(&buffer, request)
+
+	err := z.executeSend(telnetConn, cmd, buffer.Bytes())
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := z.readBinaryResponse(telnetConn)
+	if err != nil {
+		return nil, err
+	}
+
+	if response.opcode != request.opcode || response.opaque != request.opaque {
+		telnetConn.markBroken()
+		return nil, fmt.Errorf("unexpected binary response on command %s: opcode 0x%02x", cmd, response.opcode)
+	}
+
+	return response, nil
+}
+
+// binaryError - creates an error from an unexpected response status
+func (z *Zencached) binaryError(cmd memcachedCommand, response *binaryPacket) error {
+
+	return fmt.Errorf("memcached operation error on command %s: status 0x%04x %s", cmd, response.status, response.value)
+}
+
+// binaryExpiration - converts the text ttl to the binary expiration
+func (z *Zencached) binaryExpiration(ttl []byte) (uint32, error) {
+
+	if len(ttl) == 0 {
+		return 0, nil
+	}
+
+	expiration, err := strconv.ParseUint(string(ttl), 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid ttl for the binary protocol: %s", ttl)
+	}
+
+	return uint32(expiration), nil
+}
+
+// baseBinaryStorage - the base storage operation using the binary protocol (cas is only compared if not zero)
+func (z *Zencached) baseBinaryStorage(telnetConn *Telnet, cmd memcachedCommand, key, value, ttl []byte, flags uint32, casUnique uint64) (binaryStatus, error) {
+
+	opcode, ok := binaryStorageOpcodes[string(cmd)]
+	if !ok {
+		return 0, fmt.Errorf("invalid storage command: %s", cmd)
+	}
+
+	request := &binaryPacket{
+		opcode: opcode,
+		cas:    casUnique,
+		key:    key,
+		value:  value,
+	}
+
+	if opcode != binaryOpAppend && opcode != binaryOpPrepend {
+
+		expiration, err := z.binaryExpiration(ttl)
+		if err != nil {
+			return 0, err
+		}
+
+		request.extras = make([]byte, binaryStorageExtras)
+		binary.BigEndian.PutUint32(request.extras[0:4], flags)
+		binary.BigEndian.PutUint32(request.extras[4:8], expiration)
+	}
+
+	response, err := z.executeBinary(telnetConn, cmd, request)
+	if err != nil {
+		return 0, err
+	}
+
+	switch response.status {
+	case binaryStatusNoError, binaryStatusKeyNotFound, binaryStatusKeyExists, binaryStatusItemNotStored:
+		return response.status, nil
+	default:
+		return 0, z.binaryError(cmd, response)
+	}
+}
+
+// baseBinaryGet - the base get operation using the binary protocol
+func (z *Zencached) baseBinaryGet(telnetConn *Telnet, cmd memcachedCommand, key []byte) (*Item, error) {
+
+	response, err := z.executeBinary(telnetConn, cmd, &binaryPacket{
+		opcode: binaryOpGet,
+		key:    key,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	switch response.status {
+	case binaryStatusNoError:
+		return z.binaryItem(key, response)
+	case binaryStatusKeyNotFound:
+		return nil, nil
+	default:
+		return nil, z.binaryError(cmd, response)
+	}
+}
+
+// binaryItem - creates an item from a get response
+func (z *Zencached) binaryItem(key []byte, response *binaryPacket) (*Item, error) {
+
+	if len(response.extras) < 4 {
+		return nil, fmt.Errorf("no flags found on the binary get response")
+	}
+
+	return &Item{
+		Key:   key,
+		Value: response.value,
+		Flags: binary.BigEndian.Uint32(response.extras[0:4]),
+		CAS:   response.cas,
+	}, nil
+}
+
+// baseBinaryGetMulti - pipelines quiet gets (only hits are answered) terminated by a no-op
+func (z *Zencached) baseBinaryGetMulti(telnetConn *Telnet, keys [][]byte) ([]*Item, error) {
+
+	buffer := bytes.Buffer{}
+
+	for i := 0; i < len(keys); i++ {
+		z.renderBinaryRequest(&buffer, &binaryPacket{
+			opcode: binaryOpGetKQ,
+			opaque: uint32(i),
+			key:    keys[i],
+		})
+	}
+
+	z.renderBinaryRequest(&buffer, &binaryPacket{
+		opcode: binaryOpNoop,
+		opaque: uint32(len(keys)),
+	})
+
+	err := z.executeSend(telnetConn, get, buffer.Bytes())
+	if err != nil {
+		return nil, err
+	}
+
+	items := []*Item{}
+
+	for {
+		response, err := z.readBinaryResponse(telnetConn)
+		if err != nil {
+			return nil, err
+		}
+
+		if response.opcode == binaryOpNoop {
+			return items, nil
+		}
+
+		// the remaining responses of the pipeline are left unread
+		if response.status != binaryStatusNoError {
+			telnetConn.markBroken()
+			return nil, z.binaryError(get, response)
+		}
+
+		if int(response.opaque) >= len(keys) {
+			telnetConn.markBroken()
+			return nil, fmt.Errorf("unexpected binary response opaque: %d", response.opaque)
+		}
+
+		item, err := z.binaryItem(keys[response.opaque], response)
+		if err != nil {
+			telnetConn.markBroken()
+			return nil, err
+		}
+
+		items = append(items, item)
+	}
+}
+
+// baseBinaryDelete - the base delete operation using the binary protocol
+func (z *Zencached) baseBinaryDelete(telnetConn *Telnet, key []byte) (bool, error) {
+
+	response, err := z.executeBinary(telnetConn, delete, &binaryPacket{
+		opcode: binaryOpDelete,
+		key:    key,
+	})
+	if err != nil {
+		return false, err
+	}
+
+	switch response.status {
+	case binaryStatusNoError:
+		return true, nil
+	case binaryStatusKeyNotFound:
+		return false, nil
+	default:
+		return false, z.binaryError(delete, response)
+	}
+}
@@ -0,0 +1,186 @@
+package zencached_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/uol/zencached"
+)
+
+// createBinaryZencached - creates a new client using the binary protocol
+func createBinaryZencached() *zencached.Zencached {
+
+	c := &zencached.Configuration{
+		Nodes:                 setupMemcachedDocker(),
+		NumConnectionsPerNode: 3,
+		Protocol:              zencached.BinaryProtocol,
+		TelnetConfiguration:   *createTelnetConf(),
+	}
+
+	numNodes = len(c.Nodes)
+
+	z, err := zencached.New(c, nil)
+	if err != nil {
+		panic(err)
+	}
+
+	return z
+}
+
+// TestBinaryStorageCommands - tests the storage commands using the binary protocol
+func TestBinaryStorageCommands(t *testing.T) {
+
+	z := createBinaryZencached()
+	defer z.Shutdown()
+
+	key := []byte("binary-storage")
+
+	f := func(cmd []byte, value string, expectedStored bool, expectedValue string, testIndex int) {
+
+		stored, err := z.StorageWithFlags(cmd, nil, key, []byte(value), defaultTTL, uint32(testIndex))
+		if !assert.NoErrorf(t, err, "unexpected error on test %d", testIndex) {
+			return
+		}
+
+		if !assert.Equalf(t, expectedStored, stored, "unexpected storage status for test %d", testIndex) {
+			return
+		}
+
+		item, found, err := z.GetItem(nil, key)
+		if !assert.NoErrorf(t, err, "unexpected error on test %d", testIndex) {
+			return
+		}
+
+		if expectedValue == "" {
+			assert.Falsef(t, found, "expected no value for test %d", testIndex)
+			return
+		}
+
+		if !assert.Truef(t, found, "expected a value for test %d", testIndex) {
+			return
+		}
+
+		assert.Equalf(t, []byte(expectedValue), item.Value, "unexpected value for test %d", testIndex)
+	}
+
+	_, err := z.Delete(nil, key)
+	if err != nil {
+		panic(err)
+	}
+
+	f(zencached.Replace, "b", false, "", 1)
+	f(zencached.Append, "c", false, "", 2)
+	f(zencached.Add, "b\r\nEND\r\n", true, "b\r\nEND\r\n", 3)
+	f(zencached.Add, "x", false, "b\r\nEND\r\n", 4)
+	f(zencached.Set, "b", true, "b", 5)
+	f(zencached.Append, "c", true, "bc", 6)
+	f(zencached.Prepend, "a", true, "abc", 7)
+	f(zencached.Replace, "x", true, "x", 8)
+
+	item, _, err := z.GetItem(nil, key)
+	if err != nil {
+		panic(err)
+	}
+
+	assert.Equal(t, uint32(8), item.Flags, "expected the flags from the last replace")
+}
+
+// TestBinaryCompareAndSwap - tests the cas operation using the binary protocol
+func TestBinaryCompareAndSwap(t *testing.T) {
+
+	z := createBinaryZencached()
+	defer z.Shutdown()
+
+	key := []byte("binary-cas")
+
+	_, err := z.Storage(zencached.Set, nil, key, []byte("v1"), defaultTTL)
+	if err != nil {
+		panic(err)
+	}
+
+	item, found, err := z.Gets(nil, key)
+	if !assert.NoError(t, err, "unexpected error on gets") || !assert.True(t, found, "expected the item") {
+		return
+	}
+
+	result, err := z.CompareAndSwap(nil, &zencached.Item{Key: key, Value: []byte("v2"), CAS: item.CAS}, defaultTTL)
+	if assert.NoError(t, err, "unexpected error on cas") {
+		assert.Equal(t, zencached.CASStored, result, "expected the item to be stored")
+	}
+
+	result, err = z.CompareAndSwap(nil, &zencached.Item{Key: key, Value: []byte("v3"), CAS: item.CAS}, defaultTTL)
+	if assert.NoError(t, err, "unexpected error on cas") {
+		assert.Equal(t, zencached.CASExists, result, "expected a cas mismatch")
+	}
+
+	deleted, err := z.Delete(nil, key)
+	if !assert.NoError(t, err, "unexpected error on delete") || !assert.True(t, deleted, "expected the item to be deleted") {
+		return
+	}
+
+	result, err = z.CompareAndSwap(nil, &zencached.Item{Key: key, Value: []byte("v4"), CAS: item.CAS}, defaultTTL)
+	if assert.NoError(t, err, "unexpected error on cas") {
+		assert.Equal(t, zencached.CASNotFound, result, "expected the item to not be found")
+	}
+
+	deleted, err = z.Delete(nil, key)
+	if assert.NoError(t, err, "unexpected error on delete") {
+		assert.False(t, deleted, "expected the item to not be deleted")
+	}
+}
+
+// TestBinaryGetMulti - tests the pipelined quiet gets using the binary protocol
+func TestBinaryGetMulti(t *testing.T) {
+
+	z := createBinaryZencached()
+	defer z.Shutdown()
+
+	keys := [][]byte{}
+	expected := map[string][]byte{}
+
+	for i := 0; i < 20; i++ {
+
+		key := []byte(fmt.Sprintf("binary-multi%d", i))
+		keys = append(keys, key)
+
+		if i%3 == 0 {
+			_, err := z.Delete(nil, key)
+			if err != nil {
+				panic(err)
+			}
+			continue
+		}
+
+		value := []byte{byte(i), 0, '\r', '\n', byte(255 - i)}
+		expected[string(key)] = value
+
+		_, err := z.Storage(zencached.Set, nil, key, value, defaultTTL)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	values, err := z.GetMulti(nil, keys)
+	if !assert.NoError(t, err, "unexpected error on multi get") {
+		return
+	}
+
+	assert.Equal(t, expected, values, "expected the same values")
+}
+
+// TestBinaryUnsupportedCommands - tests if the text only commands returns an error
+func TestBinaryUnsupportedCommands(t *testing.T) {
+
+	z := createBinaryZencached()
+	defer z.Shutdown()
+
+	_, _, err := z.Increment(nil, []byte("key"), 1)
+	assert.Error(t, err, "expected an error on incr")
+
+	_, err = z.Touch(nil, []byte("key"), defaultTTL)
+	assert.Error(t, err, "expected an error on touch")
+
+	_, err = z.MetaGet(nil, []byte("key"), nil)
+	assert.Error(t, err, "expected an error on meta get")
+}
@@ -0,0 +1,191 @@
+package zencached
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/uol/logh"
+)
+
+//
+// A circuit breaker per node, failing fast the operations on a failing node.
+//
+
+// defaultCircuitBreakerOpenTimeout - used when no open timeout is configured
+const defaultCircuitBreakerOpenTimeout time.Duration = time.Second
+
+// ErrCircuitOpen - returned when the node's circuit breaker is open
+var ErrCircuitOpen error = errors.New("circuit breaker is open, node is failing")
+
+// CircuitState - the circuit breaker state
+type CircuitState int
+
+const (
+	// CircuitClosed - the operations are sent to the node
+	CircuitClosed CircuitState = iota
+
+	// CircuitOpen - the operations fail fast until the open timeout expires
+	CircuitOpen
+
+	// CircuitHalfOpen - a single trial operation is sent to the node
+	CircuitHalfOpen
+)
+
+// String - returns the state name
+func (s CircuitState) String() string {
+
+	switch s {
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+// circuitBreaker - the circuit breaker of a node
+type circuitBreaker struct {
+	mutex       sync.Mutex
+	state       CircuitState
+	failures    int
+	openedAt    time.Time
+	threshold   int
+	openTimeout time.Duration
+}
+
+// newCircuitBreaker - creates a closed circuit breaker
+func newCircuitBreaker(threshold int, openTimeout time.Duration) *circuitBreaker {
+
+	if openTimeout <= 0 {
+		openTimeout = defaultCircuitBreakerOpenTimeout
+	}
+
+	return &circuitBreaker{
+		threshold:   threshold,
+		openTimeout: openTimeout,
+	}
+}
+
+// currentState - returns the breaker state
+func (b *circuitBreaker) currentState() CircuitState {
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	return b.state
+}
+
+// allow - checks if an operation can be sent, moving to half-open after the open timeout
+// (a trial without result is retried after another open timeout)
+func (b *circuitBreaker) allow() (bool, bool) {
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.state == CircuitClosed {
+		return true, false
+	}
+
+	if time.Since(b.openedAt) < b.openTimeout {
+		return false, false
+	}
+
+	changed := b.state != CircuitHalfOpen
+	b.state = CircuitHalfOpen
+	b.openedAt = time.Now()
+
+	return true, changed
+}
+
+// record - records an operation result, returns true if the state has changed
+func (b *circuitBreaker) record(success bool) bool {
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if success {
+		b.failures = 0
+		changed := b.state != CircuitClosed
+		b.state = CircuitClosed
+		return changed
+	}
+
+	b.failures++
+
+	if b.state == CircuitHalfOpen || (b.state == CircuitClosed && b.failures >= b.threshold) {
+		b.state = CircuitOpen
+		b.openedAt = time.Now()
+		return true
+	}
+
+	return false
+}
+
+// CircuitState - returns the circuit breaker state of the node (always closed if the circuit breaker is disabled)
+func (z *Zencached) CircuitState(index int) CircuitState {
+
+	breaker := z.loadNodes().pools[index].breaker
+	if breaker == nil {
+		return CircuitClosed
+	}
+
+	return breaker.currentState()
+}
+
+// checkCircuit - returns an error if the connection's node circuit is open (the health probes are always sent)
+func (z *Zencached) checkCircuit(telnetConn *Telnet) error {
+
+	if telnetConn.breaker == nil || telnetConn.probe {
+		return nil
+	}
+
+	allowed, changed := telnetConn.breaker.allow()
+	if changed {
+		z.reportCircuitState(telnetConn, CircuitHalfOpen)
+	}
+
+	if !allowed {
+		if z.enableMetrics {
+			z.metricsCollector.Count(
+				1,
+				metricNodeCircuitRejected,
+				tagNodeName, telnetConn.GetHost(),
+			)
+		}
+
+		return ErrCircuitOpen
+	}
+
+	return nil
+}
+
+// countCircuitResult - records the operation result on the connection's node circuit
+func (z *Zencached) countCircuitResult(telnetConn *Telnet, success bool) {
+
+	if telnetConn.breaker == nil {
+		return
+	}
+
+	if telnetConn.breaker.record(success) {
+		z.reportCircuitState(telnetConn, telnetConn.breaker.currentState())
+	}
+}
+
+// reportCircuitState - logs and sends the circuit state transition
+func (z *Zencached) reportCircuitState(telnetConn *Telnet, state CircuitState) {
+
+	if logh.WarnEnabled {
+		z.logger.Warn().Msgf("circuit breaker is %s for node: %s", state, telnetConn.GetAddress())
+	}
+
+	if z.enableMetrics {
+		z.metricsCollector.Count(
+			1,
+			metricNodeCircuitState,
+			tagNodeName, telnetConn.GetHost(),
+			tagCircuitState, state.String(),
+		)
+	}
+}
@@ -0,0 +1,91 @@
+package zencached_test
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/uol/zencached"
+)
+
+// TestCircuitBreaker - tests the circuit breaker states of a failing node
+func TestCircuitBreaker(t *testing.T) {
+
+	deadNode := createClosedNode()
+	collector := &testCollector{}
+
+	c := createLocalConfiguration([]zencached.Node{deadNode}, func(c *zencached.Configuration) {
+		c.CircuitBreakerThreshold = 2
+		c.CircuitBreakerOpenTimeout = 200 * time.Millisecond
+		c.ReconnectionTimeout = 50 * time.Millisecond
+	})
+
+	z, err := zencached.New(c, collector)
+	if err != nil {
+		panic(err)
+	}
+
+	defer z.Shutdown()
+
+	key := []byte("circuit")
+
+	for i := 0; i < 2; i++ {
+		_, _, err := z.Get(nil, key)
+		assert.Errorf(t, err, "expected an error on try %d", i)
+	}
+
+	if !assert.Equal(t, zencached.CircuitOpen, z.CircuitState(0), "expected the circuit to be open") {
+		return
+	}
+
+	start := time.Now()
+	_, _, err = z.Get(nil, key)
+	assert.Equal(t, zencached.ErrCircuitOpen, err, "expected the circuit open error")
+	assert.True(t, time.Since(start) < c.ReconnectionTimeout, "expected the operation to fail fast")
+
+	server, _ := createMissServer(fmt.Sprintf("%s:%d", deadNode.Host, deadNode.Port))
+	defer server.listener.Close()
+
+	<-time.After(250 * time.Millisecond)
+
+	_, found, err := z.Get(nil, key)
+	assert.NoError(t, err, "expected the trial operation to succeed")
+	assert.False(t, found, "expected a miss")
+	assert.Equal(t, zencached.CircuitClosed, z.CircuitState(0), "expected the circuit to be closed")
+
+	assert.Equal(t, []string{"open", "half-open", "closed"}, collector.tagValues("zencached.node.circuit.state", 3), "expected all transitions")
+	assert.Equal(t, 1, collector.count("zencached.node.circuit.rejected"), "expected one rejected operation")
+}
+
+// TestHealthCheckWithOpenCircuit - tests if the health checker probes the idle connections of a node with the circuit open
+func TestHealthCheckWithOpenCircuit(t *testing.T) {
+
+	deadNode := createClosedNode()
+
+	z := createLocalZencached([]zencached.Node{deadNode}, nil, withHealthCheck(50*time.Millisecond), func(c *zencached.Configuration) {
+		c.CircuitBreakerThreshold = 2
+		c.CircuitBreakerOpenTimeout = time.Minute
+	})
+	defer z.Shutdown()
+
+	for i := 0; i < 2; i++ {
+		_, _, err := z.Get(nil, []byte("circuit"))
+		assert.Errorf(t, err, "expected an error on try %d", i)
+	}
+
+	if !assert.Equal(t, zencached.CircuitOpen, z.CircuitState(0), "expected the circuit to be open") {
+		return
+	}
+
+	server, _ := createMissServer(fmt.Sprintf("%s:%d", deadNode.Host, deadNode.Port))
+	defer server.listener.Close()
+
+	<-time.After(300 * time.Millisecond)
+
+	assert.Equal(t, uint32(1), atomic.LoadUint32(&server.connections), "expected the probes to reconnect once")
+	assert.Equal(t, uint32(0), atomic.LoadUint32(&server.disconnections), "expected the probed connection to be kept")
+	assert.True(t, atomic.LoadUint32(&server.commands) > 1, "expected the health probes")
+	assert.Equal(t, zencached.CircuitOpen, z.CircuitState(0), "expected the probes not to change the circuit")
+}
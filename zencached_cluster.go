@@ -1,53 +1,237 @@
 package zencached
 
-import "math/rand"
+import (
+	"context"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/uol/logh"
+)
 
 //
 // Functions to distribute a key to all the cluster.
 // author: rnojiri
 //
 
-// ClusterStorage - performs an full operation operation
+const (
+	// readRepairTimeout - the deadline of the background read repairs
+	readRepairTimeout time.Duration = time.Second
+
+	// maxReadRepairTTL - the greatest relative ttl, memcached reads the greater ones as unix timestamps
+	maxReadRepairTTL time.Duration = 30 * 24 * time.Hour
+)
+
+// ClusterStorage - performs a storage operation on all nodes concurrently, returning the result of each node
 func (z *Zencached) ClusterStorage(cmd memcachedCommand, key, value, ttl []byte) ([]bool, []error) {
 
-	stored := make([]bool, z.numNodeTelnetConns)
-	errors := make([]error, z.numNodeTelnetConns)
+	return z.ClusterStorageWithFlags(cmd, key, value, ttl, 0)
+}
+
+// ClusterStorageContext - same as ClusterStorage, but using the context to cancel the operation
+func (z *Zencached) ClusterStorageContext(ctx context.Context, cmd memcachedCommand, key, value, ttl []byte) ([]bool, []error) {
 
-	for i := 0; i < z.numNodeTelnetConns; i++ {
+	return z.ClusterStorageWithFlagsContext(ctx, cmd, key, value, ttl, 0)
+}
 
-		telnetConn := z.GetTelnetConnByNodeIndex(i)
-		defer z.ReturnTelnetConnection(telnetConn, i)
+// ClusterStorageWithFlags - performs a storage operation on all nodes concurrently setting the item's client flags
+func (z *Zencached) ClusterStorageWithFlags(cmd memcachedCommand, key, value, ttl []byte, flags uint32) ([]bool, []error) {
 
-		stored[i], errors[i] = z.baseStorage(telnetConn, cmd, key, value, ttl)
-	}
+	return z.ClusterStorageWithFlagsContext(context.Background(), cmd, key, value, ttl, flags)
+}
 
-	return stored, errors
+// ClusterStorageWithFlagsContext - same as ClusterStorageWithFlags, but using the context to cancel the operation
+func (z *Zencached) ClusterStorageWithFlagsContext(ctx context.Context, cmd memcachedCommand, key, value, ttl []byte, flags uint32) ([]bool, []error) {
+
+	return z.clusterFanOut(ctx, func(telnetConn *Telnet) (bool, error) {
+		return z.baseStorage(telnetConn, cmd, key, value, ttl, flags)
+	})
 }
 
-// ClusterGet - returns a full replicated key stored in the cluster
+// ClusterGet - returns a full replicated key stored in the cluster, starting by a random node and trying the next ones
+// on miss or error (the nodes missing the key are repaired if configured)
 func (z *Zencached) ClusterGet(key []byte) ([]byte, bool, error) {
 
-	index := rand.Intn(z.numNodeTelnetConns)
+	return z.ClusterGetContext(context.Background(), key)
+}
+
+// ClusterGetContext - same as ClusterGet, but using the context to cancel the operation
+func (z *Zencached) ClusterGetContext(ctx context.Context, key []byte) ([]byte, bool, error) {
+
+	nodes := z.loadNodes()
+	start := weightedRandomIndex(nodes.nodes)
+
+	var missed []int
+	var firstErr error
+
+	for i := 0; i < len(nodes.pools); i++ {
+
+		if ctx.Err() != nil {
+			return nil, false, ctx.Err()
+		}
+
+		index := (start + i) % len(nodes.pools)
 
-	telnetConn := z.GetTelnetConnByNodeIndex(index)
+		item, found, err := z.clusterGetItem(ctx, nodes.pools[index], index, key)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+
+		if !found {
+			missed = append(missed, index)
+			continue
+		}
+
+		if z.configuration.ClusterReadRepair && len(missed) > 0 {
+			z.startReadRepair(nodes, missed, item)
+		}
+
+		return item.Value, true, nil
+	}
+
+	// a node failing may have the key
+	if firstErr != nil {
+		return nil, false, firstErr
+	}
+
+	return nil, false, nil
+}
+
+// clusterGetItem - gets the item from the node
+func (z *Zencached) clusterGetItem(ctx context.Context, pool *nodePool, index int, key []byte) (*Item, bool, error) {
+
+	telnetConn, err := z.acquireTelnetConn(ctx, pool, z.configuration.ConnectionAcquireTimeout)
+	if err != nil {
+		return nil, false, err
+	}
 	defer z.ReturnTelnetConnection(telnetConn, index)
 
-	return z.baseGet(telnetConn, key)
+	return z.baseGetItem(telnetConn, get, key)
 }
 
-// ClusterDelete - deletes a key from all cluster nodes
+// startReadRepair - repairs the nodes missing the item in background, limited by the read repair timeout
+// (no repair is started while shutting down)
+func (z *Zencached) startReadRepair(nodes *nodeSet, missed []int, item *Item) {
+
+	z.nodesMutex.Lock()
+	defer z.nodesMutex.Unlock()
+
+	if atomic.LoadUint32(&z.shuttingDown) == 1 {
+		return
+	}
+
+	z.backgroundTasks.Add(1)
+
+	go func() {
+
+		defer z.backgroundTasks.Done()
+
+		ctx, cancel := context.WithTimeout(context.Background(), readRepairTimeout)
+		defer cancel()
+
+		// the shutdown waits the repair, it is cancelled instead of waiting its timeout
+		go func() {
+			select {
+			case <-z.backgroundStop:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+
+		z.repairNodes(ctx, nodes, missed, item)
+	}()
+}
+
+// repairNodes - adds the item to the nodes missing it (a value stored meanwhile is not replaced)
+func (z *Zencached) repairNodes(ctx context.Context, nodes *nodeSet, missed []int, item *Item) {
+
+	ttl := []byte(strconv.FormatInt(int64(z.configuration.ClusterReadRepairTTL/time.Second), 10))
+
+	for _, index := range missed {
+
+		telnetConn, err := z.acquireTelnetConn(ctx, nodes.pools[index], z.configuration.ConnectionAcquireTimeout)
+		if err != nil {
+			if logh.WarnEnabled {
+				z.logger.Warn().Err(err).Msgf("error repairing node: %s", nodeAddress(&nodes.pools[index].node))
+			}
+			continue
+		}
+
+		stored, err := z.baseStorage(telnetConn, Add, item.Key, item.Value, ttl, item.Flags)
+
+		z.ReturnTelnetConnection(telnetConn, index)
+
+		if err != nil {
+			if logh.WarnEnabled {
+				z.logger.Warn().Err(err).Msgf("error repairing node: %s", nodeAddress(&nodes.pools[index].node))
+			}
+			continue
+		}
+
+		if stored && z.enableMetrics {
+			z.metricsCollector.Count(
+				1,
+				metricClusterReadRepair,
+				tagNodeName, telnetConn.GetHost(),
+			)
+		}
+	}
+}
+
+// ClusterDelete - deletes a key from all cluster nodes concurrently, returning the result of each node
 func (z *Zencached) ClusterDelete(key []byte) ([]bool, []error) {
 
-	deleted := make([]bool, z.numNodeTelnetConns)
-	errors := make([]error, z.numNodeTelnetConns)
+	return z.ClusterDeleteContext(context.Background(), key)
+}
+
+// ClusterDeleteContext - same as ClusterDelete, but using the context to cancel the operation
+func (z *Zencached) ClusterDeleteContext(ctx context.Context, key []byte) ([]bool, []error) {
 
-	for i := 0; i < z.numNodeTelnetConns; i++ {
+	return z.clusterFanOut(ctx, func(telnetConn *Telnet) (bool, error) {
+		return z.baseDelete(telnetConn, key)
+	})
+}
 
-		telnetConn := z.GetTelnetConnByNodeIndex(i)
-		defer z.ReturnTelnetConnection(telnetConn, i)
+// clusterFanOut - runs the operation on all nodes concurrently, releasing each connection as soon as its node finishes
+// (the results are indexed by the node order when the operation started)
+func (z *Zencached) clusterFanOut(ctx context.Context, operation func(telnetConn *Telnet) (bool, error)) ([]bool, []error) {
 
-		deleted[i], errors[i] = z.baseDelete(telnetConn, key)
+	if z.configuration.ClusterOperationTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, z.configuration.ClusterOperationTimeout)
+		defer cancel()
 	}
 
-	return deleted, errors
+	nodes := z.loadNodes()
+
+	results := make([]bool, len(nodes.pools))
+	errors := make([]error, len(nodes.pools))
+
+	wg := sync.WaitGroup{}
+	wg.Add(len(nodes.pools))
+
+	for i := 0; i < len(nodes.pools); i++ {
+
+		go func(i int) {
+
+			defer wg.Done()
+
+			telnetConn, err := z.acquireTelnetConn(ctx, nodes.pools[i], z.configuration.ConnectionAcquireTimeout)
+			if err != nil {
+				errors[i] = err
+				return
+			}
+			defer z.ReturnTelnetConnection(telnetConn, i)
+
+			results[i], errors[i] = operation(telnetConn)
+		}(i)
+	}
+
+	wg.Wait()
+
+	return results, errors
 }
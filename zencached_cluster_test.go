@@ -2,7 +2,11 @@ package zencached_test
 
 import (
 	"bytes"
+	"context"
+	"net"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/uol/zencached"
@@ -51,6 +55,38 @@ func TestClusterStorageCommand(t *testing.T) {
 	}
 }
 
+// TestClusterStorageWithFlags - tests if the cluster storage sets the client flags on all nodes
+func TestClusterStorageWithFlags(t *testing.T) {
+
+	key := []byte("cluster-storage-flags")
+
+	z := createZencached(nil)
+	defer z.Shutdown()
+
+	stored, errors := z.ClusterStorageWithFlags(zencached.Set, key, []byte("value"), defaultTTL, 42)
+
+	for i := 0; i < numNodes; i++ {
+		if !assert.NoErrorf(t, errors[i], "unexpected error on node: %d", i) || !assert.Truef(t, stored[i], "expected storage on node: %d", i) {
+			return
+		}
+
+		telnetConn := z.GetTelnetConnByNodeIndex(i)
+		defer z.ReturnTelnetConnection(telnetConn, i)
+
+		err := telnetConn.Send([]byte("get " + string(key) + "\r\n"))
+		if err != nil {
+			panic(err)
+		}
+
+		response, err := telnetConn.Read([][]byte{[]byte("END")})
+		if err != nil {
+			panic(err)
+		}
+
+		assert.Truef(t, bytes.HasPrefix(response, []byte("VALUE "+string(key)+" 42 ")), "expected the flags stored on node: %d", i)
+	}
+}
+
 // rawSetKeyOnAllNodes - set the key and value on all nodes
 func rawSetKeyOnAllNodes(z *zencached.Zencached, key, value string) {
 
@@ -141,3 +177,186 @@ func TestClusterDeleteCommand(t *testing.T) {
 		}
 	}
 }
+
+// createDelayedServer - creates a local server answering the storage and delete commands after the delay
+func createDelayedServer(delay time.Duration) (*scriptedServer, zencached.Node) {
+
+	return createScriptedServer(func(conn net.Conn, connNumber int, line string) bool {
+
+		if strings.HasPrefix(line, "set ") {
+			return true
+		}
+
+		<-time.After(delay)
+
+		if strings.HasPrefix(line, "delete ") {
+			conn.Write([]byte("DELETED\r\n"))
+		} else {
+			conn.Write([]byte("STORED\r\n"))
+		}
+
+		return true
+	})
+}
+
+// TestClusterOperationsInParallel - tests if the cluster operations are sent to all nodes concurrently
+func TestClusterOperationsInParallel(t *testing.T) {
+
+	nodes := []zencached.Node{}
+
+	for i := 0; i < 3; i++ {
+		server, node := createDelayedServer(200 * time.Millisecond)
+		defer server.listener.Close()
+
+		nodes = append(nodes, node)
+	}
+
+	z := createLocalZencached(nodes, nil)
+	defer z.Shutdown()
+
+	start := time.Now()
+	stored, errors := z.ClusterStorage(zencached.Set, []byte("key"), []byte("value"), defaultTTL)
+	elapsed := time.Since(start)
+
+	assert.Equal(t, []bool{true, true, true}, stored, "expected the value stored on all nodes")
+	assert.Equal(t, []error{nil, nil, nil}, errors, "expected no errors")
+	assert.True(t, elapsed < 500*time.Millisecond, "expected the nodes called concurrently, elapsed: %s", elapsed)
+
+	start = time.Now()
+	deleted, errors := z.ClusterDelete([]byte("key"))
+	elapsed = time.Since(start)
+
+	assert.Equal(t, []bool{true, true, true}, deleted, "expected the value deleted on all nodes")
+	assert.Equal(t, []error{nil, nil, nil}, errors, "expected no errors")
+	assert.True(t, elapsed < 500*time.Millisecond, "expected the nodes called concurrently, elapsed: %s", elapsed)
+}
+
+// TestClusterOperationDeadline - tests if a slow node does not delay the results beyond the overall deadline
+func TestClusterOperationDeadline(t *testing.T) {
+
+	fastServer, fastNode := createDelayedServer(0)
+	defer fastServer.listener.Close()
+
+	slowServer, slowNode := createDelayedServer(time.Second)
+	defer slowServer.listener.Close()
+
+	z := createLocalZencached([]zencached.Node{fastNode, slowNode}, nil, func(c *zencached.Configuration) {
+		c.ClusterOperationTimeout = 200 * time.Millisecond
+	})
+	defer z.Shutdown()
+
+	start := time.Now()
+	deleted, errors := z.ClusterDelete([]byte("key"))
+	elapsed := time.Since(start)
+
+	assert.True(t, elapsed < 600*time.Millisecond, "expected the overall deadline, elapsed: %s", elapsed)
+	assert.True(t, deleted[0], "expected the value deleted on the fast node")
+	assert.NoError(t, errors[0], "unexpected error on the fast node")
+	assert.False(t, deleted[1], "expected no result from the slow node")
+	assert.Equal(t, context.DeadlineExceeded, errors[1], "expected the deadline error on the slow node")
+
+	stored, errors := z.ClusterStorage(zencached.Set, []byte("key"), []byte("value"), defaultTTL)
+
+	assert.True(t, stored[0], "expected the value stored on the fast node")
+	assert.NoError(t, errors[0], "unexpected error on the fast node")
+	assert.Equal(t, context.DeadlineExceeded, errors[1], "expected the deadline error on the slow node")
+}
+
+// withReadRepair - enables the cluster read repair
+func withReadRepair(ttl time.Duration) configOption {
+
+	return func(c *zencached.Configuration) {
+		c.ClusterReadRepair = true
+		c.ClusterReadRepairTTL = ttl
+	}
+}
+
+// TestClusterGetFallback - tests if the cluster get tries the other nodes on miss or error
+func TestClusterGetFallback(t *testing.T) {
+
+	server1, node1 := createStoreServer()
+	defer server1.listener.Close()
+
+	server2, node2 := createStoreServer()
+	defer server2.listener.Close()
+
+	server2.setValue("key", "value")
+
+	z := createLocalZencached([]zencached.Node{createClosedNode(), node1, node2}, nil)
+	defer z.Shutdown()
+
+	for i := 0; i < 20; i++ {
+
+		value, found, err := z.ClusterGet([]byte("key"))
+		if !assert.NoErrorf(t, err, "unexpected error on tentative: %d", i) || !assert.Truef(t, found, "expected the value on tentative: %d", i) {
+			return
+		}
+
+		if !assert.Equal(t, []byte("value"), value, "expected the stored value") {
+			return
+		}
+	}
+
+	_, ok := server1.value("key")
+	assert.False(t, ok, "expected no repair")
+
+	_, found, err := z.ClusterGet([]byte("other"))
+	assert.Error(t, err, "expected the failed node error")
+	assert.False(t, found, "expected a miss")
+}
+
+// TestClusterGetReadRepair - tests if the nodes missing the value are repaired
+func TestClusterGetReadRepair(t *testing.T) {
+
+	servers := []*storeServer{}
+	nodes := []zencached.Node{}
+
+	for i := 0; i < 3; i++ {
+		server, node := createStoreServer()
+		defer server.listener.Close()
+
+		servers = append(servers, server)
+		nodes = append(nodes, node)
+	}
+
+	servers[0].setValue("key", "value")
+
+	collector := &testCollector{}
+
+	z := createLocalZencached(nodes, collector, withReadRepair(time.Hour))
+	defer z.Shutdown()
+
+	for i := 0; i < 50; i++ {
+
+		_, found, err := z.ClusterGet([]byte("key"))
+		if !assert.NoErrorf(t, err, "unexpected error on tentative: %d", i) || !assert.Truef(t, found, "expected the value on tentative: %d", i) {
+			return
+		}
+	}
+
+	// the repairs run in background
+	<-time.After(100 * time.Millisecond)
+
+	for i, server := range servers {
+		value, ok := server.value("key")
+		assert.Truef(t, ok, "expected the value on node: %d", i)
+		assert.Equalf(t, "value", value, "expected the same value on node: %d", i)
+	}
+
+	assert.Equal(t, 2, collector.count("zencached.cluster.read.repair"), "expected a repair for each node missing the value")
+
+	_, found, err := z.ClusterGet([]byte("other"))
+	assert.NoError(t, err, "unexpected error on a miss")
+	assert.False(t, found, "expected a miss on all nodes")
+}
+
+// TestClusterReadRepairTTL - tests if the read repair requires a relative ttl
+func TestClusterReadRepairTTL(t *testing.T) {
+
+	for _, ttl := range []time.Duration{0, 500 * time.Millisecond, 31 * 24 * time.Hour} {
+
+		_, err := zencached.New(createLocalConfiguration(createStaticNodes(1), withReadRepair(ttl)), nil)
+
+		assert.Errorf(t, err, "expected an error with the read repair ttl: %s", ttl)
+	}
+}
@@ -0,0 +1,157 @@
+package zencached_test
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/uol/zencached"
+)
+
+// createSilentServer - creates a local server reading all requests without ever answering
+func createSilentServer() (zencached.Node, net.Listener) {
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic(err)
+	}
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			go func(conn net.Conn) {
+				defer conn.Close()
+				io.Copy(ioutil.Discard, conn)
+			}(conn)
+		}
+	}()
+
+	node := zencached.Node{
+		Host: "127.0.0.1",
+		Port: listener.Addr().(*net.TCPAddr).Port,
+	}
+
+	return node, listener
+}
+
+// TestContextDeadlineOnRead - tests if the context deadline aborts a read before the configured read timeout
+func TestContextDeadlineOnRead(t *testing.T) {
+
+	node, listener := createSilentServer()
+	defer listener.Close()
+
+	z := createLocalZencached([]zencached.Node{node}, nil, withRouting(zencached.ModuloRouting))
+	defer z.Shutdown()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, _, err := z.GetContext(ctx, nil, []byte("key"))
+
+	assert.Equal(t, context.DeadlineExceeded, err, "expected the deadline error")
+	assert.True(t, time.Since(start) < 2*time.Second, "expected the read to be aborted by the context")
+}
+
+// TestContextCancelOnRead - tests if the context cancellation aborts a pending read
+func TestContextCancelOnRead(t *testing.T) {
+
+	node, listener := createSilentServer()
+	defer listener.Close()
+
+	z := createLocalZencached([]zencached.Node{node}, nil, withRouting(zencached.ModuloRouting))
+	defer z.Shutdown()
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	go func() {
+		<-time.After(100 * time.Millisecond)
+		cancel()
+	}()
+
+	start := time.Now()
+	_, err := z.DeleteContext(ctx, nil, []byte("key"))
+
+	assert.Equal(t, context.Canceled, err, "expected the cancellation error")
+	assert.True(t, time.Since(start) < 2*time.Second, "expected the read to be aborted by the context")
+}
+
+// TestContextWhileWaitingConnection - tests if the context stops the wait for an idle connection
+func TestContextWhileWaitingConnection(t *testing.T) {
+
+	node, listener := createSilentServer()
+	defer listener.Close()
+
+	z := createLocalZencached([]zencached.Node{node}, nil, withRouting(zencached.ModuloRouting))
+	defer z.Shutdown()
+
+	telnetConn := z.GetTelnetConnByNodeIndex(0)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	_, err := z.StorageContext(ctx, zencached.Set, nil, []byte("key"), []byte("value"), defaultTTL)
+	assert.Equal(t, context.DeadlineExceeded, err, "expected the deadline error")
+
+	stored, errs := z.ClusterStorageContext(ctx, zencached.Set, []byte("key"), []byte("value"), defaultTTL)
+	assert.Equal(t, []bool{false}, stored, "expected nothing stored")
+	assert.Equal(t, []error{context.DeadlineExceeded}, errs, "expected the deadline error")
+
+	z.ReturnTelnetConnection(telnetConn, 0)
+}
+
+// TestContextCancelledConnectionReuse - tests if the connections used by cancelled operations are still usable
+func TestContextCancelledConnectionReuse(t *testing.T) {
+
+	z := createZencached(nil)
+	defer z.Shutdown()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	key := []byte("context")
+
+	for i := 0; i < 10; i++ {
+		_, err := z.StorageContext(ctx, zencached.Set, nil, key, []byte("cancelled"), defaultTTL)
+		assert.Equalf(t, context.Canceled, err, "expected the cancellation error on try %d", i)
+	}
+
+	_, err := z.Storage(zencached.Set, nil, key, []byte("value"), defaultTTL)
+	if !assert.NoError(t, err, "unexpected error on set") {
+		return
+	}
+
+	value, found, err := z.GetContext(context.Background(), nil, key)
+	if !assert.NoError(t, err, "unexpected error on get") || !assert.True(t, found, "expected the value") {
+		return
+	}
+
+	assert.Equal(t, []byte("value"), value, "expected the same value")
+}
+
+// TestContextOnReconnect - tests if the context deadline aborts the reconnection backoff
+func TestContextOnReconnect(t *testing.T) {
+
+	z := createLocalZencached([]zencached.Node{createClosedNode()}, nil, func(c *zencached.Configuration) {
+		c.ReconnectionTimeout = 5 * time.Second
+		c.MaxWriteRetries = 3
+	})
+	defer z.Shutdown()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, _, err := z.GetContext(ctx, nil, []byte("key"))
+
+	assert.Equal(t, context.DeadlineExceeded, err, "expected the deadline error")
+	assert.True(t, time.Since(start) < time.Second, "expected the reconnection aborted by the context")
+}
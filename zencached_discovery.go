@@ -0,0 +1,249 @@
+package zencached
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/uol/logh"
+)
+
+//
+// The discovery of the nodes, refreshed periodically and applied to the pool without a restart.
+//
+
+// defaultDiscoveryTimeout - the max time to discover the nodes
+const defaultDiscoveryTimeout time.Duration = 5 * time.Second
+
+// Discoverer - discovers the current nodes, implementations must be safe for concurrent use
+type Discoverer interface {
+
+	// Discover - returns the current nodes
+	Discover(ctx context.Context) ([]Node, error)
+}
+
+// Resolver - the DNS lookups used by the discoverers (implemented by net.Resolver)
+type Resolver interface {
+
+	// LookupHost - returns the addresses of the host
+	LookupHost(ctx context.Context, host string) ([]string, error)
+
+	// LookupSRV - returns the service records
+	LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
+}
+
+// DNSDiscoverer - discovers the nodes resolving all A/AAAA records of a host (a headless service, for example)
+type DNSDiscoverer struct {
+
+	// Host - the host name to resolve
+	Host string
+
+	// Port - the port used by all nodes
+	Port int
+
+	// Resolver - the resolver used (net.DefaultResolver if nil)
+	Resolver Resolver
+}
+
+// Discover - returns a node for each address of the host
+func (d *DNSDiscoverer) Discover(ctx context.Context) ([]Node, error) {
+
+	addresses, err := discoveryResolver(d.Resolver).LookupHost(ctx, d.Host)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := make([]Node, len(addresses))
+	for i := 0; i < len(addresses); i++ {
+		nodes[i] = Node{
+			Host: addresses[i],
+			Port: d.Port,
+		}
+	}
+
+	sortNodes(nodes)
+
+	return nodes, nil
+}
+
+// SRVDiscoverer - discovers the nodes resolving the SRV records ("_service._proto.name"),
+// only the targets with the lowest priority are used and their weights are kept
+type SRVDiscoverer struct {
+
+	// Service - the service name (empty to lookup the name directly)
+	Service string
+
+	// Proto - the service protocol (usually "tcp")
+	Proto string
+
+	// Name - the domain name
+	Name string
+
+	// Resolver - the resolver used (net.DefaultResolver if nil)
+	Resolver Resolver
+}
+
+// Discover - returns a node for each target of the service records
+func (d *SRVDiscoverer) Discover(ctx context.Context) ([]Node, error) {
+
+	_, records, err := discoveryResolver(d.Resolver).LookupSRV(ctx, d.Service, d.Proto, d.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	var lowestPriority uint16
+	for i := 0; i < len(records); i++ {
+		if i == 0 || records[i].Priority < lowestPriority {
+			lowestPriority = records[i].Priority
+		}
+	}
+
+	nodes := []Node{}
+
+	for i := 0; i < len(records); i++ {
+		if records[i].Priority != lowestPriority {
+			continue
+		}
+
+		nodes = append(nodes, Node{
+			Host:   strings.TrimSuffix(records[i].Target, "."),
+			Port:   int(records[i].Port),
+			Weight: int(records[i].Weight),
+		})
+	}
+
+	sortNodes(nodes)
+
+	return nodes, nil
+}
+
+// discoveryResolver - returns the resolver or the default one
+func discoveryResolver(resolver Resolver) Resolver {
+
+	if resolver == nil {
+		return net.DefaultResolver
+	}
+
+	return resolver
+}
+
+// sortNodes - sorts the nodes by address, keeping the order of the discovered nodes stable among lookups
+func sortNodes(nodes []Node) {
+
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodeAddress(&nodes[i]) < nodeAddress(&nodes[j])
+	})
+}
+
+// discoverNodes - discovers the nodes using the configured discoverer
+func (z *Zencached) discoverNodes() ([]Node, error) {
+
+	ctx, cancel := context.WithTimeout(context.Background(), defaultDiscoveryTimeout)
+	defer cancel()
+
+	nodes, err := z.configuration.Discoverer.Discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(nodes) == 0 {
+		return nil, fmt.Errorf("no nodes discovered")
+	}
+
+	return nodes, nil
+}
+
+// RefreshNodes - discovers the nodes and applies the changes to the pool (the current nodes are kept on error),
+// the current nodes keep their order and the new ones are appended (removing a node still remaps the keys
+// of the following nodes on the modulo and jump hash routing)
+func (z *Zencached) RefreshNodes() error {
+
+	if z.configuration.Discoverer == nil {
+		return fmt.Errorf("no discoverer configured")
+	}
+
+	discovered, err := z.discoverNodes()
+	if err != nil {
+		return err
+	}
+
+	nodes := mergeNodes(z.loadNodes().nodes, discovered)
+
+	if sameNodes(z.loadNodes().nodes, nodes) {
+		return nil
+	}
+
+	if logh.InfoEnabled {
+		z.logger.Info().Msgf("discovered nodes have changed, %d nodes found", len(nodes))
+	}
+
+	return z.ReplaceNodes(nodes)
+}
+
+// mergeNodes - returns the current nodes still discovered in their order (using the discovered weights) followed by the new ones
+func mergeNodes(current, discovered []Node) []Node {
+
+	nodes := make([]Node, 0, len(discovered))
+	added := make([]bool, len(discovered))
+
+	for i := 0; i < len(current); i++ {
+		for j := 0; j < len(discovered); j++ {
+			if !added[j] && nodeAddress(&current[i]) == nodeAddress(&discovered[j]) {
+				nodes = append(nodes, discovered[j])
+				added[j] = true
+				break
+			}
+		}
+	}
+
+	for j := 0; j < len(discovered); j++ {
+		if !added[j] {
+			nodes = append(nodes, discovered[j])
+		}
+	}
+
+	return nodes
+}
+
+// sameNodes - checks if both node lists are equal
+func sameNodes(a, b []Node) bool {
+
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := 0; i < len(a); i++ {
+		if nodeAddress(&a[i]) != nodeAddress(&b[i]) || a[i].weight() != b[i].weight() {
+			return false
+		}
+	}
+
+	return true
+}
+
+// runDiscovery - refreshes the nodes periodically
+func (z *Zencached) runDiscovery() {
+
+	defer z.backgroundTasks.Done()
+
+	ticker := time.NewTicker(z.configuration.DiscoveryInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-z.backgroundStop:
+			return
+		case <-ticker.C:
+		}
+
+		err := z.RefreshNodes()
+		if err != nil {
+			if logh.WarnEnabled {
+				z.logger.Warn().Err(err).Msg("error refreshing the nodes, keeping the current ones")
+			}
+		}
+	}
+}
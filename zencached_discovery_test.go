@@ -0,0 +1,197 @@
+package zencached_test
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/uol/zencached"
+)
+
+// fakeResolver - a resolver answering with the configured records (safe for concurrent use)
+type fakeResolver struct {
+	mutex     sync.Mutex
+	addresses []string
+	records   []*net.SRV
+	err       error
+}
+
+func (r *fakeResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.addresses, r.err
+}
+
+func (r *fakeResolver) LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return "", r.records, r.err
+}
+
+// set - replaces the records and the error
+func (r *fakeResolver) set(records []*net.SRV, err error) {
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.records = records
+	r.err = err
+}
+
+// srvRecords - creates a record for each node
+func srvRecords(nodes ...zencached.Node) []*net.SRV {
+
+	records := make([]*net.SRV, len(nodes))
+	for i := 0; i < len(nodes); i++ {
+		records[i] = &net.SRV{
+			Target: nodes[i].Host + ".",
+			Port:   uint16(nodes[i].Port),
+		}
+	}
+
+	return records
+}
+
+// TestDNSDiscoverer - tests if a node is created for each host address
+func TestDNSDiscoverer(t *testing.T) {
+
+	discoverer := &zencached.DNSDiscoverer{
+		Host: "memcached.default.svc",
+		Port: 11211,
+		Resolver: &fakeResolver{
+			addresses: []string{"10.0.0.2", "10.0.0.1", "fd00::1"},
+		},
+	}
+
+	nodes, err := discoverer.Discover(context.Background())
+	if !assert.NoError(t, err, "unexpected error discovering") {
+		return
+	}
+
+	assert.Equal(t,
+		[]zencached.Node{
+			{Host: "10.0.0.1", Port: 11211},
+			{Host: "10.0.0.2", Port: 11211},
+			{Host: "fd00::1", Port: 11211},
+		},
+		nodes,
+		"expected the sorted nodes",
+	)
+}
+
+// TestSRVDiscoverer - tests if only the lowest priority targets are used
+func TestSRVDiscoverer(t *testing.T) {
+
+	discoverer := &zencached.SRVDiscoverer{
+		Service: "memcached",
+		Proto:   "tcp",
+		Name:    "memcached.default.svc",
+		Resolver: &fakeResolver{
+			records: []*net.SRV{
+				{Target: "backup.default.svc.", Port: 11211, Priority: 20, Weight: 1},
+				{Target: "mc-1.default.svc.", Port: 11211, Priority: 10, Weight: 2},
+				{Target: "mc-0.default.svc.", Port: 11212, Priority: 10, Weight: 0},
+			},
+		},
+	}
+
+	nodes, err := discoverer.Discover(context.Background())
+	if !assert.NoError(t, err, "unexpected error discovering") {
+		return
+	}
+
+	assert.Equal(t,
+		[]zencached.Node{
+			{Host: "mc-0.default.svc", Port: 11212},
+			{Host: "mc-1.default.svc", Port: 11211, Weight: 2},
+		},
+		nodes,
+		"expected the lowest priority nodes",
+	)
+}
+
+// TestDiscoveryRefresh - tests if the discovered node changes are applied periodically
+func TestDiscoveryRefresh(t *testing.T) {
+
+	server1, node1 := createMissServer("")
+	defer server1.listener.Close()
+
+	server2, node2 := createMissServer("")
+	defer server2.listener.Close()
+
+	// the node discovered first sorts after the added one, it must keep its index
+	if fmt.Sprintf("%s:%d", node1.Host, node1.Port) < fmt.Sprintf("%s:%d", node2.Host, node2.Port) {
+		node1, node2 = node2, node1
+	}
+
+	resolver := &fakeResolver{
+		records: srvRecords(node1),
+	}
+
+	z := createLocalZencached(nil, nil, withRouting(zencached.KetamaRouting), func(c *zencached.Configuration) {
+		c.Discoverer = &zencached.SRVDiscoverer{
+			Name:     "memcached.default.svc",
+			Resolver: resolver,
+		}
+		c.DiscoveryInterval = 50 * time.Millisecond
+	})
+	defer z.Shutdown()
+
+	assert.Equal(t, []zencached.Node{node1}, z.Nodes(), "expected the discovered node")
+
+	resolver.set(srvRecords(node1, node2), nil)
+	<-time.After(200 * time.Millisecond)
+
+	assert.Equal(t, []zencached.Node{node1, node2}, z.Nodes(), "expected the added node after the current one")
+
+	resolver.set(nil, fmt.Errorf("dns failure"))
+	<-time.After(200 * time.Millisecond)
+
+	assert.Equal(t, []zencached.Node{node1, node2}, z.Nodes(), "expected the nodes kept on error")
+
+	resolver.set(srvRecords(node2), nil)
+	<-time.After(200 * time.Millisecond)
+
+	assert.Equal(t, []zencached.Node{node2}, z.Nodes(), "expected the removed node")
+
+	_, _, err := z.Get(nil, []byte("key"))
+	assert.NoError(t, err, "unexpected error on get")
+}
+
+// TestDiscoveryFailureOnStartup - tests if the configured nodes are used when the discovery fails
+func TestDiscoveryFailureOnStartup(t *testing.T) {
+
+	node := zencached.Node{Host: "127.0.0.1", Port: 11211}
+
+	c := createLocalConfiguration(nil, func(c *zencached.Configuration) {
+		c.Discoverer = &zencached.DNSDiscoverer{
+			Host:     "memcached.default.svc",
+			Port:     11211,
+			Resolver: &fakeResolver{err: fmt.Errorf("dns failure")},
+		}
+	})
+
+	_, err := zencached.New(c, nil)
+	if !assert.Error(t, err, "expected an error without configured nodes") {
+		return
+	}
+
+	c.Nodes = []zencached.Node{node}
+
+	z, err := zencached.New(c, nil)
+	if !assert.NoError(t, err, "expected the configured nodes") {
+		return
+	}
+	defer z.Shutdown()
+
+	assert.Equal(t, []zencached.Node{node}, z.Nodes(), "expected the configured node")
+	assert.Error(t, z.RefreshNodes(), "expected the discovery error")
+}
@@ -0,0 +1,183 @@
+package zencached
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/uol/logh"
+)
+
+//
+// Temporary ejection of the failing nodes. The keys of an ejected node are rerouted
+// to the live nodes, the keys owned by the live nodes are never moved.
+//
+
+// defaultEjectionProbeInterval - used when no probe interval is configured
+const defaultEjectionProbeInterval time.Duration = time.Second
+
+// failoverRouting - routes the keys of the ejected nodes among the live nodes
+type failoverRouting struct {
+	nodes   *nodeSet
+	router  Router
+	indexes []int
+}
+
+// IsEjected - checks if the node is ejected from the routing
+func (z *Zencached) IsEjected(index int) bool {
+
+	return z.loadNodes().pools[index].isEjected()
+}
+
+// isEjected - checks if the node is ejected from the routing
+func (p *nodePool) isEjected() bool {
+
+	return atomic.LoadUint32(&p.ejected) == 1
+}
+
+// failoverIndex - returns the live node index for a key owned by an ejected node (the same index if all nodes are ejected
+// or the node set has changed)
+func (z *Zencached) failoverIndex(nodes *nodeSet, routerHash []byte, index int) int {
+
+	// a router instance can not be created again, the next live replica of the key is used instead
+	if z.configuration.Router != nil {
+		for _, replica := range nodes.replicas(routerHash, len(nodes.pools)) {
+			if !nodes.pools[replica].isEjected() {
+				return replica
+			}
+		}
+
+		return index
+	}
+
+	failover := z.failover.Load().(*failoverRouting)
+	if failover.nodes != nodes || len(failover.indexes) == 0 {
+		return index
+	}
+
+	return failover.indexes[failover.router.Route(routerHash)]
+}
+
+// countNodeResult - counts the consecutive failures of the node, ejecting it when the threshold is reached
+func (z *Zencached) countNodeResult(pool *nodePool, success bool) {
+
+	if z.configuration.EjectionThreshold <= 0 {
+		return
+	}
+
+	if success {
+		atomic.StoreUint32(&pool.failures, 0)
+		return
+	}
+
+	failures := atomic.AddUint32(&pool.failures, 1)
+	if int(failures) < z.configuration.EjectionThreshold {
+		return
+	}
+
+	if !atomic.CompareAndSwapUint32(&pool.ejected, 0, 1) {
+		return
+	}
+
+	if logh.WarnEnabled {
+		z.logger.Warn().Msgf("ejecting node after %d consecutive failures: %s", failures, nodeAddress(&pool.node))
+	}
+
+	z.rebuildFailover()
+	z.countEjection(metricNodeEjected, pool)
+}
+
+// readmitNode - readmits an ejected node to the routing
+func (z *Zencached) readmitNode(pool *nodePool) {
+
+	atomic.StoreUint32(&pool.failures, 0)
+
+	if !atomic.CompareAndSwapUint32(&pool.ejected, 1, 0) {
+		return
+	}
+
+	if logh.InfoEnabled {
+		z.logger.Info().Msgf("readmitting node: %s", nodeAddress(&pool.node))
+	}
+
+	z.rebuildFailover()
+	z.countEjection(metricNodeReadmitted, pool)
+}
+
+// rebuildFailover - rebuilds the failover router using the live nodes
+func (z *Zencached) rebuildFailover() {
+
+	z.nodesMutex.Lock()
+	defer z.nodesMutex.Unlock()
+
+	z.storeFailover(z.loadNodes())
+}
+
+// storeFailover - builds the failover router using the live nodes of the node set, not used by a router instance
+// (must be called holding the nodes mutex)
+func (z *Zencached) storeFailover(nodes *nodeSet) {
+
+	failover := &failoverRouting{
+		nodes: nodes,
+	}
+	liveNodes := []Node{}
+
+	for i := 0; i < len(nodes.pools); i++ {
+		if !nodes.pools[i].isEjected() {
+			liveNodes = append(liveNodes, nodes.nodes[i])
+			failover.indexes = append(failover.indexes, i)
+		}
+	}
+
+	if len(liveNodes) > 0 && z.configuration.Router == nil {
+		failover.router = z.configuration.newEmptyRouter()
+		failover.router.Rebuild(liveNodes)
+	}
+
+	z.failover.Store(failover)
+}
+
+// countEjection - sends the ejection or readmission metric
+func (z *Zencached) countEjection(metric string, pool *nodePool) {
+
+	if !z.enableMetrics {
+		return
+	}
+
+	z.metricsCollector.Count(
+		1,
+		metric,
+		tagNodeName, pool.node.Host,
+	)
+}
+
+// runEjectedNodesProbe - probes the ejected nodes periodically, readmitting the healthy ones
+func (z *Zencached) runEjectedNodesProbe() {
+
+	defer z.backgroundTasks.Done()
+
+	interval := z.configuration.EjectionProbeInterval
+	if interval <= 0 {
+		interval = defaultEjectionProbeInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-z.backgroundStop:
+			return
+		case <-ticker.C:
+		}
+
+		for _, pool := range z.loadNodes().pools {
+			if !pool.isEjected() {
+				continue
+			}
+
+			if healthy, _ := z.checkIdleConnection(pool); healthy {
+				z.readmitNode(pool)
+			}
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package zencached_test
+
+import (
+	"fmt"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/uol/zencached"
+)
+
+// withEjection - sets the node ejection
+func withEjection(threshold int, probeInterval time.Duration) configOption {
+
+	return func(c *zencached.Configuration) {
+		c.EjectionThreshold = threshold
+		c.EjectionProbeInterval = probeInterval
+	}
+}
+
+// TestNodeEjectionAndReadmission - tests if a failing node is ejected, its keys rerouted and readmitted after recovering
+func TestNodeEjectionAndReadmission(t *testing.T) {
+
+	server1, node1 := createMissServer("")
+	defer server1.listener.Close()
+
+	server2, node2 := createMissServer("")
+	defer server2.listener.Close()
+
+	deadNode := createClosedNode()
+
+	collector := &testCollector{}
+
+	z := createLocalZencached([]zencached.Node{node1, node2, deadNode}, collector, withRouting(zencached.KetamaRouting), withEjection(2, 50*time.Millisecond))
+	defer z.Shutdown()
+
+	keys := createKeys(300)
+
+	before := routeKeys(z, keys)
+
+	var deadKey []byte
+	for i := 0; i < len(keys); i++ {
+		if before[i] == 2 {
+			deadKey = keys[i]
+			break
+		}
+	}
+
+	if !assert.NotNil(t, deadKey, "expected a key routed to the dead node") {
+		return
+	}
+
+	for i := 0; i < 2; i++ {
+		_, _, err := z.Get(nil, deadKey)
+		assert.Errorf(t, err, "expected an error on try %d", i)
+	}
+
+	if !assert.True(t, z.IsEjected(2), "expected the dead node to be ejected") {
+		return
+	}
+
+	assert.Equal(t, 1, collector.count("zencached.node.ejected"), "expected the ejection metric")
+
+	_, found, err := z.Get(nil, deadKey)
+	assert.NoError(t, err, "expected the key to be rerouted")
+	assert.False(t, found, "expected a miss from the live node")
+
+	after := routeKeys(z, keys)
+
+	for i := 0; i < len(keys); i++ {
+		if before[i] == 2 {
+			assert.NotEqualf(t, 2, after[i], "expected the key %s to be rerouted", keys[i])
+		} else {
+			assert.Equalf(t, before[i], after[i], "expected the key %s to stay on the same node", keys[i])
+		}
+	}
+
+	server3, _ := createMissServer(fmt.Sprintf("%s:%d", deadNode.Host, deadNode.Port))
+	defer server3.listener.Close()
+
+	<-time.After(300 * time.Millisecond)
+
+	if !assert.False(t, z.IsEjected(2), "expected the node to be readmitted") {
+		return
+	}
+
+	assert.Equal(t, 1, collector.count("zencached.node.readmitted"), "expected the readmission metric")
+	assert.Equal(t, before, routeKeys(z, keys), "expected the original routing")
+}
+
+// TestErrorResponseDoesNotEjectNode - tests if the memcached error replies are not counted as node failures
+func TestErrorResponseDoesNotEjectNode(t *testing.T) {
+
+	server, node := createScriptedServer(func(conn net.Conn, connNumber int, line string) bool {
+
+		conn.Write([]byte("SERVER_ERROR out of memory\r\n"))
+
+		return true
+	})
+	defer server.listener.Close()
+
+	collector := &testCollector{}
+
+	z := createLocalZencached([]zencached.Node{node}, collector, withRouting(zencached.KetamaRouting), withEjection(2, 50*time.Millisecond))
+	defer z.Shutdown()
+
+	for i := 0; i < 5; i++ {
+		_, _, err := z.Get(nil, []byte("key"))
+		assert.Errorf(t, err, "expected the error reply on try %d", i)
+	}
+
+	assert.False(t, z.IsEjected(0), "expected the node not to be ejected")
+	assert.Equal(t, 0, collector.count("zencached.node.ejected"), "expected no ejection metric")
+}
+
+// TestCustomRouterFailover - tests if the keys of an ejected node are rerouted using the custom router
+func TestCustomRouterFailover(t *testing.T) {
+
+	server1, node1 := createMissServer("")
+	defer server1.listener.Close()
+
+	server2, node2 := createMissServer("")
+	defer server2.listener.Close()
+
+	z := createLocalZencached([]zencached.Node{node1, node2, createClosedNode()}, nil, withEjection(2, time.Minute), withRouter(&fixedRouter{index: 2}))
+	defer z.Shutdown()
+
+	for i := 0; i < 2; i++ {
+		_, _, err := z.Get(nil, []byte("key"))
+		assert.Errorf(t, err, "expected an error on try %d", i)
+	}
+
+	if !assert.True(t, z.IsEjected(2), "expected the dead node to be ejected") {
+		return
+	}
+
+	_, found, err := z.Get(nil, []byte("key"))
+	assert.NoError(t, err, "expected the key to be rerouted")
+	assert.False(t, found, "expected a miss from the live node")
+	assert.Equal(t, []int{0, 0}, routeKeys(z, createKeys(2)), "expected the next node of the custom router")
+}
+
+// TestHealthCheckDoesNotEjectNode - tests if the health probes finding connections closed by the server do not eject the node
+func TestHealthCheckDoesNotEjectNode(t *testing.T) {
+
+	// the server closes each connection after answering, like an idle timeout
+	server, node := createScriptedServer(func(conn net.Conn, connNumber int, line string) bool {
+
+		conn.Write([]byte("VERSION 1.6.0\r\n"))
+
+		return false
+	})
+	defer server.listener.Close()
+
+	z := createLocalZencached([]zencached.Node{node}, nil, withEjection(1, time.Minute), withHealthCheck(30*time.Millisecond))
+	defer z.Shutdown()
+
+	<-time.After(300 * time.Millisecond)
+
+	assert.True(t, atomic.LoadUint32(&server.commands) > 2, "expected the health probes")
+	assert.False(t, z.IsEjected(0), "expected the node not to be ejected by the health probes")
+}
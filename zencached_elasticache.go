@@ -0,0 +1,198 @@
+package zencached
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+//
+// The ElastiCache style auto discovery, polling the cluster configuration from a configuration endpoint.
+// More information here:
+// https://docs.aws.amazon.com/AmazonElastiCache/latest/mem-ug/AutoDiscovery.AddingToYourClientLibrary.html
+//
+
+var (
+	// configGetCluster - returns the cluster configuration
+	configGetCluster memcachedCommand = memcachedCommand("config get cluster")
+
+	mcrConfig []byte = []byte("CONFIG")
+)
+
+// ElastiCacheDiscoverer - discovers the nodes using the "config get cluster" command of the configuration endpoint,
+// the node list is only parsed again when the configuration version changes
+type ElastiCacheDiscoverer struct {
+
+	// Endpoint - the configuration endpoint
+	Endpoint Node
+
+	// TelnetConfiguration - the configuration of the connection to the endpoint
+	TelnetConfiguration TelnetConfiguration
+
+	mutex   sync.Mutex
+	telnet  *Telnet
+	version int
+	nodes   []Node
+}
+
+// Discover - returns the nodes of the current cluster configuration
+func (d *ElastiCacheDiscoverer) Discover(ctx context.Context) ([]Node, error) {
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if d.telnet == nil {
+
+		telnet, err := NewTelnet(&d.Endpoint, &d.TelnetConfiguration)
+		if err != nil {
+			return nil, err
+		}
+
+		err = telnet.Connect()
+		if err != nil {
+			return nil, err
+		}
+
+		d.telnet = telnet
+	}
+
+	d.telnet.bindContext(ctx)
+
+	version, body, err := d.readConfig()
+
+	d.telnet.unbindContext()
+
+	if err != nil || d.telnet.IsBroken() {
+		d.telnet.Close()
+		d.telnet = nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if d.nodes == nil || version != d.version {
+
+		nodes, err := parseClusterNodes(body)
+		if err != nil {
+			return nil, err
+		}
+
+		d.version = version
+		d.nodes = nodes
+	}
+
+	nodes := make([]Node, len(d.nodes))
+	copy(nodes, d.nodes)
+
+	return nodes, nil
+}
+
+// Close - closes the connection to the configuration endpoint
+func (d *ElastiCacheDiscoverer) Close() {
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if d.telnet != nil {
+		d.telnet.Close()
+		d.telnet = nil
+	}
+}
+
+// readConfig - sends the command and reads the configuration version and the node list
+func (d *ElastiCacheDiscoverer) readConfig() (int, []byte, error) {
+
+	err := d.telnet.Send([]byte(string(configGetCluster) + "\r\n"))
+	if err != nil {
+		return 0, nil, err
+	}
+
+	header, err := d.telnet.ReadLine()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	// CONFIG cluster <flags> <bytes>
+	fields := bytes.Fields(header)
+	if len(fields) != 4 || !bytes.Equal(fields[0], mcrConfig) {
+		d.telnet.markBroken()
+		return 0, nil, fmt.Errorf("memcached operation error on command %s: %s", configGetCluster, header)
+	}
+
+	length, err := strconv.Atoi(string(fields[3]))
+	if err != nil {
+		d.telnet.markBroken()
+		return 0, nil, fmt.Errorf("invalid configuration length: %s", fields[3])
+	}
+
+	payload, err := d.telnet.ReadFull(length)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	for {
+		line, err := d.telnet.ReadLine()
+		if err != nil {
+			return 0, nil, err
+		}
+
+		if bytes.Equal(line, mcrEnd) {
+			break
+		}
+
+		if len(line) > 0 {
+			d.telnet.markBroken()
+			return 0, nil, fmt.Errorf("unexpected configuration line: %s", line)
+		}
+	}
+
+	// <version>\n<hostname|ip|port> ...\n
+	lines := strings.SplitN(strings.TrimSpace(string(payload)), "\n", 2)
+	if len(lines) != 2 {
+		return 0, nil, fmt.Errorf("invalid cluster configuration: %q", payload)
+	}
+
+	version, err := strconv.Atoi(strings.TrimSpace(lines[0]))
+	if err != nil {
+		return 0, nil, fmt.Errorf("invalid cluster configuration version: %s", lines[0])
+	}
+
+	return version, []byte(lines[1]), nil
+}
+
+// parseClusterNodes - parses the "hostname|ip|port" list, the ip is used when available
+func parseClusterNodes(body []byte) ([]Node, error) {
+
+	nodes := []Node{}
+
+	for _, entry := range strings.Fields(string(body)) {
+
+		parts := strings.Split(entry, "|")
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("invalid cluster node: %s", entry)
+		}
+
+		port, err := strconv.Atoi(parts[2])
+		if err != nil {
+			return nil, fmt.Errorf("invalid cluster node port: %s", entry)
+		}
+
+		host := parts[1]
+		if len(host) == 0 {
+			host = parts[0]
+		}
+
+		nodes = append(nodes, Node{
+			Host: host,
+			Port: port,
+		})
+	}
+
+	sortNodes(nodes)
+
+	return nodes, nil
+}
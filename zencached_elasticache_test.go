@@ -0,0 +1,131 @@
+package zencached_test
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/uol/zencached"
+)
+
+// clusterConfig - the cluster configuration served by the fake configuration endpoint
+type clusterConfig struct {
+	version int
+	nodes   []zencached.Node
+}
+
+// createConfigServer - creates a local configuration endpoint answering the current cluster configuration (closing the connection if nil)
+func createConfigServer(config *atomic.Value) (*scriptedServer, zencached.Node) {
+
+	return createScriptedServer(func(conn net.Conn, connNumber int, line string) bool {
+
+		current := config.Load().(*clusterConfig)
+		if line != "config get cluster" || current == nil {
+			return false
+		}
+
+		entries := make([]string, len(current.nodes))
+		for i := 0; i < len(current.nodes); i++ {
+			entries[i] = fmt.Sprintf("localhost|%s|%d", current.nodes[i].Host, current.nodes[i].Port)
+		}
+
+		payload := fmt.Sprintf("%d\n%s\n", current.version, strings.Join(entries, " "))
+
+		conn.Write([]byte(fmt.Sprintf("CONFIG cluster 0 %d\r\n%s\r\nEND\r\n", len(payload), payload)))
+
+		return true
+	})
+}
+
+// TestElastiCacheDiscoverer - tests if the node list is only changed when the configuration version changes
+func TestElastiCacheDiscoverer(t *testing.T) {
+
+	node1 := zencached.Node{Host: "10.0.0.1", Port: 11211}
+	node2 := zencached.Node{Host: "10.0.0.2", Port: 11211}
+
+	config := &atomic.Value{}
+	config.Store(&clusterConfig{version: 1, nodes: []zencached.Node{node2, node1}})
+
+	server, endpoint := createConfigServer(config)
+	defer server.listener.Close()
+
+	discoverer := &zencached.ElastiCacheDiscoverer{
+		Endpoint:            endpoint,
+		TelnetConfiguration: *createTelnetConf(),
+	}
+	defer discoverer.Close()
+
+	nodes, err := discoverer.Discover(context.Background())
+	if !assert.NoError(t, err, "unexpected error discovering") {
+		return
+	}
+
+	assert.Equal(t, []zencached.Node{node1, node2}, nodes, "expected the sorted nodes")
+
+	config.Store(&clusterConfig{version: 1, nodes: []zencached.Node{node1}})
+
+	nodes, err = discoverer.Discover(context.Background())
+	if !assert.NoError(t, err, "unexpected error discovering") {
+		return
+	}
+
+	assert.Equal(t, []zencached.Node{node1, node2}, nodes, "expected the same nodes for the same version")
+
+	config.Store(&clusterConfig{version: 2, nodes: []zencached.Node{node1}})
+
+	nodes, err = discoverer.Discover(context.Background())
+	if !assert.NoError(t, err, "unexpected error discovering") {
+		return
+	}
+
+	assert.Equal(t, []zencached.Node{node1}, nodes, "expected the nodes of the new version")
+	assert.Equal(t, uint32(1), atomic.LoadUint32(&server.connections), "expected the endpoint connection to be reused")
+}
+
+// TestElastiCacheDiscoveryRefresh - tests if the client is reconfigured when the cluster configuration changes
+func TestElastiCacheDiscoveryRefresh(t *testing.T) {
+
+	server1, node1 := createMissServer("")
+	defer server1.listener.Close()
+
+	server2, node2 := createMissServer("")
+	defer server2.listener.Close()
+
+	config := &atomic.Value{}
+	config.Store(&clusterConfig{version: 1, nodes: []zencached.Node{node1}})
+
+	server, endpoint := createConfigServer(config)
+	defer server.listener.Close()
+
+	discoverer := &zencached.ElastiCacheDiscoverer{
+		Endpoint:            endpoint,
+		TelnetConfiguration: *createTelnetConf(),
+	}
+	defer discoverer.Close()
+
+	z := createLocalZencached(nil, nil, withRouting(zencached.KetamaRouting), func(c *zencached.Configuration) {
+		c.Discoverer = discoverer
+		c.DiscoveryInterval = 50 * time.Millisecond
+	})
+	defer z.Shutdown()
+
+	assert.Equal(t, []zencached.Node{node1}, z.Nodes(), "expected the configured cluster node")
+
+	config.Store(&clusterConfig{version: 2, nodes: []zencached.Node{node1, node2}})
+	<-time.After(200 * time.Millisecond)
+
+	assert.ElementsMatch(t, []zencached.Node{node1, node2}, z.Nodes(), "expected the added cluster node")
+
+	config.Store((*clusterConfig)(nil))
+	<-time.After(200 * time.Millisecond)
+
+	assert.ElementsMatch(t, []zencached.Node{node1, node2}, z.Nodes(), "expected the nodes kept while the endpoint is down")
+
+	_, _, err := z.Get(nil, []byte("key"))
+	assert.NoError(t, err, "unexpected error on get")
+}
@@ -0,0 +1,153 @@
+package zencached
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	"github.com/uol/logh"
+)
+
+//
+// The background health checker of the idle connections.
+//
+
+var (
+	// version - returns the server version
+	version memcachedCommand = memcachedCommand("version")
+
+	// noop - the binary no-op command
+	noop memcachedCommand = memcachedCommand("noop")
+
+	mcrVersion []byte = []byte("VERSION")
+)
+
+// startBackgroundTasks - starts the health checker, the ejected nodes prober and the discovery refresh, if configured
+func (z *Zencached) startBackgroundTasks() {
+
+	if z.configuration.HealthCheckInterval > 0 {
+		z.backgroundTasks.Add(1)
+		go z.runHealthCheck()
+	}
+
+	if z.configuration.EjectionThreshold > 0 {
+		z.backgroundTasks.Add(1)
+		go z.runEjectedNodesProbe()
+	}
+
+	if z.configuration.Discoverer != nil && z.configuration.DiscoveryInterval > 0 {
+		z.backgroundTasks.Add(1)
+		go z.runDiscovery()
+	}
+}
+
+// stopBackgroundTasks - stops the background tasks and waits them to return their connections
+func (z *Zencached) stopBackgroundTasks() {
+
+	close(z.backgroundStop)
+	z.backgroundTasks.Wait()
+}
+
+// runHealthCheck - checks the idle connections of all nodes periodically
+func (z *Zencached) runHealthCheck() {
+
+	defer z.backgroundTasks.Done()
+
+	ticker := time.NewTicker(z.configuration.HealthCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-z.backgroundStop:
+			return
+		case <-ticker.C:
+		}
+
+		for _, pool := range z.loadNodes().pools {
+			for c := 0; c < z.configuration.NumConnectionsPerNode; c++ {
+				select {
+				case <-z.backgroundStop:
+					return
+				default:
+				}
+
+				if _, checked := z.checkIdleConnection(pool); !checked {
+					break
+				}
+			}
+		}
+	}
+}
+
+// checkIdleConnection - checks an idle connection of the node, returns if it is healthy and false if there is no idle connection to check
+func (z *Zencached) checkIdleConnection(pool *nodePool) (bool, bool) {
+
+	var telnetConn *Telnet
+
+	select {
+	case telnetConn = <-pool.conns:
+	default:
+		return false, false
+	}
+
+	telnetConn.probe = true
+
+	err := z.checkTelnetConnection(telnetConn)
+	if err != nil {
+		if logh.WarnEnabled {
+			z.logger.Warn().Err(err).Msgf("health check failed on node: %s", telnetConn.GetAddress())
+		}
+
+		telnetConn.markBroken()
+	}
+
+	z.ReturnTelnetConnection(telnetConn, -1)
+
+	return err == nil, true
+}
+
+// checkTelnetConnection - sends a version (or a binary no-op) command, closed connections are reconnected by the send
+func (z *Zencached) checkTelnetConnection(telnetConn *Telnet) error {
+
+	if z.isBinaryProtocol() {
+		_, err := z.executeBinary(telnetConn, noop, &binaryPacket{
+			opcode: binaryOpNoop,
+		})
+
+		return err
+	}
+
+	err := z.executeSend(telnetConn, version, []byte("version\r\n"))
+	if err != nil {
+		return err
+	}
+
+	response, err := telnetConn.ReadLine()
+	if err != nil {
+		return err
+	}
+
+	if !bytes.HasPrefix(response, mcrVersion) {
+		return fmt.Errorf("memcached operation error on command %s: %s", version, response)
+	}
+
+	return nil
+}
+
+// recycleTelnetConnection - closes a broken connection, the next send reconnects it
+func (z *Zencached) recycleTelnetConnection(telnetConn *Telnet) {
+
+	if logh.DebugEnabled {
+		z.logger.Debug().Msgf("recycling connection to node: %s", telnetConn.GetAddress())
+	}
+
+	telnetConn.Close()
+
+	if z.enableMetrics {
+		z.metricsCollector.Count(
+			1,
+			metricNodeConnRecycled,
+			tagNodeName, telnetConn.GetHost(),
+		)
+	}
+}
@@ -0,0 +1,218 @@
+package zencached_test
+
+import (
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/uol/zencached"
+)
+
+// withReadTimeout - sets the maximum read timeout
+func withReadTimeout(timeout time.Duration) configOption {
+
+	return func(c *zencached.Configuration) {
+		c.MaxReadTimeout = timeout
+	}
+}
+
+// withHealthCheck - sets the health check interval
+func withHealthCheck(interval time.Duration) configOption {
+
+	return func(c *zencached.Configuration) {
+		c.HealthCheckInterval = interval
+	}
+}
+
+// TestPoisonedConnectionIsReplaced - tests if a connection timing out in the middle of a response is not reused
+func TestPoisonedConnectionIsReplaced(t *testing.T) {
+
+	server, node := createScriptedServer(func(conn net.Conn, connNumber int, line string) bool {
+
+		if connNumber == 1 {
+			conn.Write([]byte("VALUE key 0 10\r\nslow"))
+			<-time.After(300 * time.Millisecond)
+			conn.Write([]byte("-value\r\nEND\r\n"))
+			return true
+		}
+
+		conn.Write([]byte("VALUE key 0 5\r\nvalue\r\nEND\r\n"))
+
+		return true
+	})
+	defer server.listener.Close()
+
+	z := createLocalZencached([]zencached.Node{node}, nil, withReadTimeout(100*time.Millisecond))
+	defer z.Shutdown()
+
+	_, _, err := z.Get(nil, []byte("key"))
+	if !assert.Error(t, err, "expected a timeout error") {
+		return
+	}
+
+	value, found, err := z.Get(nil, []byte("key"))
+	if !assert.NoError(t, err, "unexpected error on get") || !assert.True(t, found, "expected the value") {
+		return
+	}
+
+	assert.Equal(t, []byte("value"), value, "expected the value from the new connection")
+	assert.Equal(t, uint32(2), atomic.LoadUint32(&server.connections), "expected a new connection")
+}
+
+// TestProtocolErrorConnectionIsReplaced - tests if a connection returning an unexpected response is not reused
+func TestProtocolErrorConnectionIsReplaced(t *testing.T) {
+
+	server, node := createScriptedServer(func(conn net.Conn, connNumber int, line string) bool {
+
+		if connNumber == 1 {
+			conn.Write([]byte("UNEXPECTED\r\nSTORED\r\n"))
+			return true
+		}
+
+		conn.Write([]byte("STORED\r\n"))
+
+		return true
+	})
+	defer server.listener.Close()
+
+	z := createLocalZencached([]zencached.Node{node}, nil, withReadTimeout(100*time.Millisecond))
+	defer z.Shutdown()
+
+	_, err := z.Delete(nil, []byte("key"))
+	if !assert.Error(t, err, "expected a protocol error") {
+		return
+	}
+
+	stored, err := z.Storage(zencached.Set, nil, []byte("key"), []byte("value"), defaultTTL)
+	if !assert.NoError(t, err, "unexpected error on set") {
+		return
+	}
+
+	assert.True(t, stored, "expected the value to be stored")
+	assert.Equal(t, uint32(2), atomic.LoadUint32(&server.connections), "expected a new connection")
+}
+
+// TestErrorResponseKeepsConnection - tests if a connection returning a complete error response is reused
+func TestErrorResponseKeepsConnection(t *testing.T) {
+
+	server, node := createScriptedServer(func(conn net.Conn, connNumber int, line string) bool {
+
+		switch {
+		case strings.HasPrefix(line, "incr "):
+			conn.Write([]byte("CLIENT_ERROR cannot increment or decrement non-numeric value\r\n"))
+		case strings.HasPrefix(line, "delete "):
+			conn.Write([]byte("SERVER_ERROR out of memory\r\n"))
+		case strings.HasPrefix(line, "get "):
+			conn.Write([]byte("CLIENT_ERROR bad command line format\r\n"))
+		default:
+			conn.Write([]byte("ERROR\r\n"))
+		}
+
+		return true
+	})
+	defer server.listener.Close()
+
+	z := createLocalZencached([]zencached.Node{node}, nil, withReadTimeout(100*time.Millisecond))
+	defer z.Shutdown()
+
+	_, _, err := z.Increment(nil, []byte("key"), 1)
+	assert.Error(t, err, "expected the client error")
+
+	_, err = z.Delete(nil, []byte("key"))
+	assert.Error(t, err, "expected the server error")
+
+	_, _, err = z.Get(nil, []byte("key"))
+	assert.Error(t, err, "expected the client error")
+
+	assert.Equal(t, uint32(1), atomic.LoadUint32(&server.connections), "expected the connection to be reused")
+	assert.Equal(t, uint32(3), atomic.LoadUint32(&server.commands), "expected all commands on the same connection")
+}
+
+// TestStorageErrorResponseReplacesConnection - tests if an error reply to a command with a data block discards the connection,
+// the server runs the rejected data block as another command
+func TestStorageErrorResponseReplacesConnection(t *testing.T) {
+
+	server, node := createScriptedServer(func(conn net.Conn, connNumber int, line string) bool {
+
+		switch {
+		case strings.HasPrefix(line, "set "):
+			conn.Write([]byte("CLIENT_ERROR bad command line format\r\n"))
+		case strings.HasPrefix(line, "get "):
+			conn.Write([]byte("VALUE key 0 5\r\nvalue\r\nEND\r\n"))
+		default:
+			conn.Write([]byte("ERROR\r\n"))
+		}
+
+		return true
+	})
+	defer server.listener.Close()
+
+	z := createLocalZencached([]zencached.Node{node}, nil, withReadTimeout(100*time.Millisecond))
+	defer z.Shutdown()
+
+	_, err := z.Storage(zencached.Set, nil, []byte("key"), []byte("value"), []byte("abc"))
+	assert.Error(t, err, "expected the client error")
+
+	for i := 0; i < 2; i++ {
+		value, found, err := z.Get(nil, []byte("key"))
+		if assert.NoErrorf(t, err, "expected no reply from the previous command on try %d", i) && assert.Truef(t, found, "expected the value on try %d", i) {
+			assert.Equal(t, []byte("value"), value, "expected the value of the requested key")
+		}
+	}
+
+	assert.Equal(t, uint32(2), atomic.LoadUint32(&server.connections), "expected the connection to be replaced")
+
+	_, _, err = z.Get(nil, []byte("other"))
+	assert.Error(t, err, "expected an error on the value of another key")
+
+	_, _, err = z.Get(nil, []byte("key"))
+	assert.NoError(t, err, "unexpected error after the unexpected key")
+	assert.Equal(t, uint32(3), atomic.LoadUint32(&server.connections), "expected the connection to be replaced after the unexpected key")
+}
+
+// TestHealthCheckRecyclesDeadConnections - tests if the health checker replaces the connections closed by the server
+func TestHealthCheckRecyclesDeadConnections(t *testing.T) {
+
+	server, node := createScriptedServer(func(conn net.Conn, connNumber int, line string) bool {
+
+		if line != "version" {
+			return false
+		}
+
+		conn.Write([]byte("VERSION 1.6.0\r\n"))
+
+		// the first connection dies after the first check
+		return connNumber != 1
+	})
+	defer server.listener.Close()
+
+	z := createLocalZencached([]zencached.Node{node}, nil, withReadTimeout(100*time.Millisecond), withHealthCheck(50*time.Millisecond))
+	defer z.Shutdown()
+
+	<-time.After(500 * time.Millisecond)
+
+	assert.True(t, atomic.LoadUint32(&server.connections) >= 2, "expected the dead connection to be replaced")
+	assert.True(t, atomic.LoadUint32(&server.commands) >= 3, "expected the idle connection to be checked")
+}
+
+// TestConcurrentShutdown - tests if concurrent shutdowns stop the background tasks only once
+func TestConcurrentShutdown(t *testing.T) {
+
+	z := createLocalZencached(createStaticNodes(1), nil, withHealthCheck(50*time.Millisecond))
+
+	var wg sync.WaitGroup
+	wg.Add(10)
+
+	for i := 0; i < 10; i++ {
+		go func() {
+			defer wg.Done()
+			z.Shutdown()
+		}()
+	}
+
+	wg.Wait()
+}
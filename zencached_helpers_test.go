@@ -0,0 +1,197 @@
+package zencached_test
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/uol/zencached"
+)
+
+// configOption - overrides a field of the local test configuration
+type configOption func(c *zencached.Configuration)
+
+// withRouting - sets the routing algorithm
+func withRouting(algorithm zencached.RoutingAlgorithm) configOption {
+
+	return func(c *zencached.Configuration) {
+		c.RoutingAlgorithm = algorithm
+	}
+}
+
+// createLocalConfiguration - creates a configuration for the local nodes with fast reconnections
+func createLocalConfiguration(nodes []zencached.Node, options ...configOption) *zencached.Configuration {
+
+	c := &zencached.Configuration{
+		Nodes:                 nodes,
+		NumConnectionsPerNode: 1,
+		TelnetConfiguration:   *createTelnetConf(),
+	}
+
+	c.ReconnectionTimeout = 10 * time.Millisecond
+	c.MaxWriteRetries = 2
+
+	for _, option := range options {
+		option(c)
+	}
+
+	return c
+}
+
+// createLocalZencached - creates a new client for the local nodes
+func createLocalZencached(nodes []zencached.Node, metricsCollector zencached.MetricsCollector, options ...configOption) *zencached.Zencached {
+
+	z, err := zencached.New(createLocalConfiguration(nodes, options...), metricsCollector)
+	if err != nil {
+		panic(err)
+	}
+
+	return z
+}
+
+// scriptedServer - a local server answering each command line using a handler
+type scriptedServer struct {
+	listener       net.Listener
+	connections    uint32
+	commands       uint32
+	disconnections uint32
+}
+
+// createScriptedServer - creates a local server, the handler receives the connection number (starting from 1) and the command line
+func createScriptedServer(handler func(conn net.Conn, connNumber int, line string) bool) (*scriptedServer, zencached.Node) {
+
+	return createScriptedServerOn("127.0.0.1:0", handler)
+}
+
+// createScriptedServerOn - creates a local server listening on the specified address
+func createScriptedServerOn(address string, handler func(conn net.Conn, connNumber int, line string) bool) (*scriptedServer, zencached.Node) {
+
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		panic(err)
+	}
+
+	server := &scriptedServer{
+		listener: listener,
+	}
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			connNumber := int(atomic.AddUint32(&server.connections, 1))
+
+			go func(conn net.Conn) {
+				defer atomic.AddUint32(&server.disconnections, 1)
+				defer conn.Close()
+
+				reader := bufio.NewReader(conn)
+				for {
+					line, err := reader.ReadString('\n')
+					if err != nil {
+						return
+					}
+
+					atomic.AddUint32(&server.commands, 1)
+
+					if !handler(conn, connNumber, strings.TrimSpace(line)) {
+						return
+					}
+				}
+			}(conn)
+		}
+	}()
+
+	node := zencached.Node{
+		Host: "127.0.0.1",
+		Port: listener.Addr().(*net.TCPAddr).Port,
+	}
+
+	return server, node
+}
+
+// createStaticNodes - creates a list of nodes that are never connected
+func createStaticNodes(numNodes int) []zencached.Node {
+
+	nodes := make([]zencached.Node, numNodes)
+
+	for i := 0; i < numNodes; i++ {
+		nodes[i] = zencached.Node{
+			Host: fmt.Sprintf("10.0.0.%d", i+1),
+			Port: 11211,
+		}
+	}
+
+	return nodes
+}
+
+// routeKeys - returns the node index of each key
+func routeKeys(z *zencached.Zencached, keys [][]byte) []int {
+
+	indexes := make([]int, len(keys))
+
+	for i, key := range keys {
+		telnetConn, index := z.GetTelnetConnection(nil, key)
+		z.ReturnTelnetConnection(telnetConn, index)
+		indexes[i] = index
+	}
+
+	return indexes
+}
+
+// createKeys - creates a list of text keys
+func createKeys(numKeys int) [][]byte {
+
+	keys := make([][]byte, numKeys)
+
+	for i := 0; i < numKeys; i++ {
+		keys[i] = []byte(fmt.Sprintf("user:session:%d", i))
+	}
+
+	return keys
+}
+
+// createClosedNode - returns a local node without any server listening
+func createClosedNode() zencached.Node {
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic(err)
+	}
+
+	listener.Close()
+
+	return zencached.Node{
+		Host: "127.0.0.1",
+		Port: listener.Addr().(*net.TCPAddr).Port,
+	}
+}
+
+// createMissServer - creates a local server answering the get commands with a miss
+func createMissServer(address string) (*scriptedServer, zencached.Node) {
+
+	handler := func(conn net.Conn, connNumber int, line string) bool {
+
+		if line == "version" {
+			conn.Write([]byte("VERSION 1.6.0\r\n"))
+		} else if strings.HasPrefix(line, "get ") {
+			conn.Write([]byte("END\r\n"))
+		} else {
+			return false
+		}
+
+		return true
+	}
+
+	if address == "" {
+		return createScriptedServer(handler)
+	}
+
+	return createScriptedServerOn(address, handler)
+}
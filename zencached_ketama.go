@@ -0,0 +1,139 @@
+package zencached
+
+import (
+	"crypto/md5"
+	"math"
+	"sort"
+	"strconv"
+)
+
+//
+// A ketama compatible consistent hashing ring.
+// More information here:
+// https://github.com/RJ/ketama
+//
+
+// ketama constants
+const (
+	ketamaPointsPerServer int = 160
+	ketamaPointsPerHash   int = 4
+)
+
+// ketamaPoint - a virtual node in the ring
+type ketamaPoint struct {
+	hash  uint32
+	index int
+}
+
+// ketamaRing - the continuum of virtual nodes
+type ketamaRing struct {
+	points []ketamaPoint
+}
+
+// newKetamaRing - creates a new ring using the libketama point generation ("host:port-i")
+func newKetamaRing(nodes []Node) *ketamaRing {
+
+	totalWeight := 0
+	for i := 0; i < len(nodes); i++ {
+		totalWeight += nodes[i].weight()
+	}
+
+	points := make([]ketamaPoint, 0, len(nodes)*ketamaPointsPerServer)
+
+	for i := 0; i < len(nodes); i++ {
+
+		address := nodeAddress(&nodes[i]) + "-"
+		numHashes := ketamaNumHashes(nodes[i].weight(), totalWeight, len(nodes))
+
+		for j := 0; j < numHashes; j++ {
+
+			digest := md5.Sum([]byte(address + strconv.Itoa(j)))
+
+			for h := 0; h < ketamaPointsPerHash; h++ {
+				points = append(points, ketamaPoint{
+					hash:  ketamaHash(digest, h),
+					index: i,
+				})
+			}
+		}
+	}
+
+	sort.Slice(points, func(a, b int) bool {
+		if points[a].hash == points[b].hash {
+			return points[a].index < points[b].index
+		}
+		return points[a].hash < points[b].hash
+	})
+
+	return &ketamaRing{
+		points: points,
+	}
+}
+
+// ketamaNumHashes - returns the number of hashes (each one has 4 points) for a node, using the same float precision as libketama
+func ketamaNumHashes(weight, totalWeight, numNodes int) int {
+
+	pct := float32(weight) / float32(totalWeight)
+
+	return int(math.Floor(float64(float32(float64(pct) * float64(ketamaPointsPerServer/ketamaPointsPerHash) * float64(numNodes)))))
+}
+
+// ketamaHash - extracts the nth little endian 32 bits hash from a md5 digest
+func ketamaHash(digest [md5.Size]byte, n int) uint32 {
+
+	return uint32(digest[3+n*4])<<24 |
+		uint32(digest[2+n*4])<<16 |
+		uint32(digest[1+n*4])<<8 |
+		uint32(digest[n*4])
+}
+
+// nodeIndex - returns the node index owning the key
+func (r *ketamaRing) nodeIndex(key []byte) int {
+
+	if len(r.points) == 0 {
+		return 0
+	}
+
+	return r.points[r.pointIndex(key)].index
+}
+
+// pointIndex - returns the index of the first point after the key hash
+func (r *ketamaRing) pointIndex(key []byte) int {
+
+	hash := ketamaHash(md5.Sum(key), 0)
+
+	i := sort.Search(len(r.points), func(i int) bool {
+		return r.points[i].hash >= hash
+	})
+
+	if i == len(r.points) {
+		i = 0
+	}
+
+	return i
+}
+
+// nodeIndexes - returns up to n distinct node indexes walking the ring clockwise from the key
+func (r *ketamaRing) nodeIndexes(key []byte, n int) []int {
+
+	if len(r.points) == 0 {
+		return []int{0}
+	}
+
+	indexes := []int{}
+	found := map[int]bool{}
+	start := r.pointIndex(key)
+
+	for i := 0; i < len(r.points) && len(indexes) < n; i++ {
+
+		index := r.points[(start+i)%len(r.points)].index
+		if found[index] {
+			continue
+		}
+
+		found[index] = true
+		indexes = append(indexes, index)
+	}
+
+	return indexes
+}
@@ -0,0 +1,92 @@
+package zencached_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/uol/zencached"
+)
+
+// TestKetamaRoutingIsStable - tests if the same key always goes to the same node
+func TestKetamaRoutingIsStable(t *testing.T) {
+
+	keys := createKeys(1000)
+
+	z1 := createLocalZencached(createStaticNodes(3), nil, withRouting(zencached.KetamaRouting))
+	z2 := createLocalZencached(createStaticNodes(3), nil, withRouting(zencached.KetamaRouting))
+
+	assert.Equal(t, routeKeys(z1, keys), routeKeys(z2, keys), "expected the same routing")
+}
+
+// TestKetamaRoutingDistribution - tests if the keys are distributed among all nodes
+func TestKetamaRoutingDistribution(t *testing.T) {
+
+	testDistribution(t, zencached.KetamaRouting)
+}
+
+// TestKetamaRoutingNodeAdded - tests if only a fraction of keys are remapped when a node is added
+func TestKetamaRoutingNodeAdded(t *testing.T) {
+
+	testRemapOnNodeAdded(t, zencached.KetamaRouting)
+}
+
+// ketamaVector - a key and the server chosen by libketama
+type ketamaVector struct {
+	key    string
+	server string
+}
+
+// testKetamaVectors - tests if the keys are routed to the same servers chosen by libketama
+func testKetamaVectors(t *testing.T, nodes []zencached.Node, vectors []ketamaVector) {
+
+	router := &zencached.KetamaRouter{}
+	router.Rebuild(nodes)
+
+	for _, vector := range vectors {
+		node := nodes[router.Route([]byte(vector.key))]
+		assert.Equalf(t, vector.server, fmt.Sprintf("%s:%d", node.Host, node.Port), "unexpected server for the key: %s", vector.key)
+	}
+}
+
+// TestKetamaCompatibility - tests the routing against known libketama assignments, using the weighted servers of
+// the libketama example file and the same servers without weights
+func TestKetamaCompatibility(t *testing.T) {
+
+	weights := []int{600, 300, 200, 350, 1000, 800, 950, 100}
+	nodes := make([]zencached.Node, len(weights))
+
+	for i := 0; i < len(weights); i++ {
+		nodes[i] = zencached.Node{
+			Host:   fmt.Sprintf("10.0.1.%d", i+1),
+			Port:   11211,
+			Weight: weights[i],
+		}
+	}
+
+	testKetamaVectors(t, nodes, []ketamaVector{
+		{"foo", "10.0.1.7:11211"},
+		{"bar", "10.0.1.6:11211"},
+		{"baz", "10.0.1.2:11211"},
+		{"user:1234", "10.0.1.5:11211"},
+		{"session:abcdef", "10.0.1.2:11211"},
+		{"ketama", "10.0.1.7:11211"},
+		{"memcached", "10.0.1.2:11211"},
+		{"a", "10.0.1.8:11211"},
+		{"zencached", "10.0.1.7:11211"},
+		{"12345", "10.0.1.5:11211"},
+		{"hello world", "10.0.1.2:11211"},
+		{"the quick brown fox", "10.0.1.7:11211"},
+	})
+
+	testKetamaVectors(t, createStaticNodes(3), []ketamaVector{
+		{"foo", "10.0.0.3:11211"},
+		{"bar", "10.0.0.1:11211"},
+		{"baz", "10.0.0.3:11211"},
+		{"user:1234", "10.0.0.1:11211"},
+		{"session:abcdef", "10.0.0.1:11211"},
+		{"ketama", "10.0.0.3:11211"},
+		{"hello world", "10.0.0.1:11211"},
+		{"the quick brown fox", "10.0.0.3:11211"},
+	})
+}
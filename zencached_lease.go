@@ -0,0 +1,123 @@
+package zencached
+
+import (
+	"context"
+	"fmt"
+)
+
+//
+// Stale-while-revalidate leases built over the meta protocol, only one caller
+// wins the right to recompute a missing or stale item while the others keep
+// receiving the stale value (or a miss).
+//
+
+// Lease - the result of a get with lease
+type Lease struct {
+
+	// Value - the item's value (may be stale)
+	Value []byte
+
+	// Flags - the item's client flags
+	Flags uint32
+
+	// CAS - the cas unique used by SetWithLease to store the recomputed value
+	CAS uint64
+
+	// Found - a value (fresh or stale) was found, the placeholders created by the lease are reported as not found
+	Found bool
+
+	// Stale - the value was invalidated and must be recomputed
+	Stale bool
+
+	// Win - this caller must recompute the value and store it using SetWithLease
+	Win bool
+}
+
+// GetWithLease - gets the item, creating a placeholder with the lease ttl on miss; the caller receives
+// the win if the item is missing, stale or its remaining ttl is lower than the recache ttl (optional)
+func (z *Zencached) GetWithLease(routerHash, key, leaseTTL, recacheTTL []byte) (*Lease, error) {
+
+	return z.GetWithLeaseContext(context.Background(), routerHash, key, leaseTTL, recacheTTL)
+}
+
+// GetWithLeaseContext - same as GetWithLease, but using the context to cancel the operation
+func (z *Zencached) GetWithLeaseContext(ctx context.Context, routerHash, key, leaseTTL, recacheTTL []byte) (*Lease, error) {
+
+	flags := NewMetaFlags().ReturnValue().ReturnCAS().ReturnClientFlags().Vivify(leaseTTL)
+	if len(recacheTTL) > 0 {
+		flags.Recache(recacheTTL)
+	}
+
+	response, err := z.MetaGetContext(ctx, routerHash, key, flags)
+	if err != nil {
+		return nil, err
+	}
+
+	lease := &Lease{
+		Value: response.Value,
+		Stale: response.Stale(),
+		Win:   response.Win(),
+	}
+
+	lease.CAS, _ = response.CAS()
+	lease.Flags, _ = response.ClientFlags()
+
+	if response.Status == MetaStatusValue {
+		lease.Found = lease.Stale || len(response.Value) > 0 || !(lease.Win || response.AlreadyWon())
+	}
+
+	return lease, nil
+}
+
+// SetWithLease - stores the recomputed value only if the lease is still valid, clearing the stale state (a nil lease always stores)
+func (z *Zencached) SetWithLease(routerHash, key, value, ttl []byte, flags uint32, lease *Lease) (bool, error) {
+
+	return z.SetWithLeaseContext(context.Background(), routerHash, key, value, ttl, flags, lease)
+}
+
+// SetWithLeaseContext - same as SetWithLease, but using the context to cancel the operation
+func (z *Zencached) SetWithLeaseContext(ctx context.Context, routerHash, key, value, ttl []byte, flags uint32, lease *Lease) (bool, error) {
+
+	metaFlags := NewMetaFlags().TTL(ttl).ClientFlags(flags)
+	if lease != nil {
+		metaFlags.CompareCAS(lease.CAS)
+	}
+
+	response, err := z.MetaSetContext(ctx, routerHash, key, value, metaFlags)
+	if err != nil {
+		return false, err
+	}
+
+	switch response.Status {
+	case MetaStatusSuccess:
+		return true, nil
+	case MetaStatusExists, MetaStatusNotFound, MetaStatusNotStored:
+		return false, nil
+	default:
+		return false, fmt.Errorf("memcached operation error on command %s: unexpected status %s", ms, response.Status)
+	}
+}
+
+// Invalidate - marks the item as stale keeping its value for the given ttl, the next GetWithLease wins the recompute
+func (z *Zencached) Invalidate(routerHash, key, ttl []byte) (bool, error) {
+
+	return z.InvalidateContext(context.Background(), routerHash, key, ttl)
+}
+
+// InvalidateContext - same as Invalidate, but using the context to cancel the operation
+func (z *Zencached) InvalidateContext(ctx context.Context, routerHash, key, ttl []byte) (bool, error) {
+
+	response, err := z.MetaDeleteContext(ctx, routerHash, key, NewMetaFlags().Invalidate().TTL(ttl))
+	if err != nil {
+		return false, err
+	}
+
+	switch response.Status {
+	case MetaStatusSuccess:
+		return true, nil
+	case MetaStatusNotFound:
+		return false, nil
+	default:
+		return false, fmt.Errorf("memcached operation error on command %s: unexpected status %s", md, response.Status)
+	}
+}
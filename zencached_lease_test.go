@@ -0,0 +1,110 @@
+package zencached_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestLeaseOnMiss - tests if only one caller wins the recompute of a missing item
+func TestLeaseOnMiss(t *testing.T) {
+
+	z := createZencached(nil)
+	defer z.Shutdown()
+
+	key := []byte("lease-miss")
+
+	_, err := z.Delete(nil, key)
+	if err != nil {
+		panic(err)
+	}
+
+	first, err := z.GetWithLease(nil, key, defaultTTL, nil)
+	if !assert.NoError(t, err, "unexpected error on the first get") {
+		return
+	}
+
+	assert.True(t, first.Win, "expected the first caller to win")
+	assert.False(t, first.Found, "expected no value")
+
+	second, err := z.GetWithLease(nil, key, defaultTTL, nil)
+	if !assert.NoError(t, err, "unexpected error on the second get") {
+		return
+	}
+
+	assert.False(t, second.Win, "expected the second caller to lose")
+	assert.False(t, second.Found, "expected no value")
+
+	stored, err := z.SetWithLease(nil, key, []byte("computed"), defaultTTL, 7, first)
+	if !assert.NoError(t, err, "unexpected error on set") || !assert.True(t, stored, "expected the value to be stored") {
+		return
+	}
+
+	stored, err = z.SetWithLease(nil, key, []byte("late"), defaultTTL, 0, first)
+	if assert.NoError(t, err, "unexpected error on set") {
+		assert.False(t, stored, "expected the expired lease to be rejected")
+	}
+
+	lease, err := z.GetWithLease(nil, key, defaultTTL, nil)
+	if !assert.NoError(t, err, "unexpected error on get") {
+		return
+	}
+
+	assert.True(t, lease.Found, "expected a value")
+	assert.False(t, lease.Win, "expected no win")
+	assert.False(t, lease.Stale, "expected a fresh value")
+	assert.Equal(t, []byte("computed"), lease.Value, "expected the computed value")
+	assert.Equal(t, uint32(7), lease.Flags, "expected the same flags")
+}
+
+// TestLeaseOnStale - tests if the stale value is served while only one caller recomputes it
+func TestLeaseOnStale(t *testing.T) {
+
+	z := createZencached(nil)
+	defer z.Shutdown()
+
+	key := []byte("lease-stale")
+
+	stored, err := z.SetWithLease(nil, key, []byte("old"), defaultTTL, 0, nil)
+	if !assert.NoError(t, err, "unexpected error on set") || !assert.True(t, stored, "expected the value to be stored") {
+		return
+	}
+
+	invalidated, err := z.Invalidate(nil, key, defaultTTL)
+	if !assert.NoError(t, err, "unexpected error on invalidate") || !assert.True(t, invalidated, "expected the item to be invalidated") {
+		return
+	}
+
+	first, err := z.GetWithLease(nil, key, defaultTTL, nil)
+	if !assert.NoError(t, err, "unexpected error on the first get") {
+		return
+	}
+
+	assert.True(t, first.Win, "expected the first caller to win")
+	assert.True(t, first.Stale, "expected a stale value")
+	assert.True(t, first.Found, "expected a value")
+	assert.Equal(t, []byte("old"), first.Value, "expected the stale value")
+
+	second, err := z.GetWithLease(nil, key, defaultTTL, nil)
+	if !assert.NoError(t, err, "unexpected error on the second get") {
+		return
+	}
+
+	assert.False(t, second.Win, "expected the second caller to lose")
+	assert.True(t, second.Stale, "expected a stale value")
+	assert.Equal(t, []byte("old"), second.Value, "expected the stale value")
+
+	stored, err = z.SetWithLease(nil, key, []byte("new"), defaultTTL, 0, first)
+	if !assert.NoError(t, err, "unexpected error on set") || !assert.True(t, stored, "expected the value to be stored") {
+		return
+	}
+
+	lease, err := z.GetWithLease(nil, key, defaultTTL, nil)
+	if !assert.NoError(t, err, "unexpected error on get") {
+		return
+	}
+
+	assert.False(t, lease.Stale, "expected the stale state to be cleared")
+	assert.False(t, lease.Win, "expected no win")
+	assert.Equal(t, []byte("new"), lease.Value, "expected the new value")
+}
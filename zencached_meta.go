@@ -0,0 +1,565 @@
+package zencached
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strconv"
+)
+
+//
+// The memcached meta commands (mg, ms, md, ma and mn).
+// More information here:
+// https://github.com/memcached/memcached/blob/master/doc/protocol.txt
+//
+
+var (
+	// mg - the meta get command
+	mg memcachedCommand = memcachedCommand("mg")
+
+	// ms - the meta set command
+	ms memcachedCommand = memcachedCommand("ms")
+
+	// md - the meta delete command
+	md memcachedCommand = memcachedCommand("md")
+
+	// ma - the meta arithmetic command
+	ma memcachedCommand = memcachedCommand("ma")
+
+	// mn - the meta no-op command
+	mn memcachedCommand = memcachedCommand("mn")
+)
+
+// MetaStatus - the status code of a meta command response
+type MetaStatus string
+
+const (
+	// MetaStatusSuccess - the command succeeded and there is no value ("HD")
+	MetaStatusSuccess MetaStatus = "HD"
+
+	// MetaStatusValue - the command succeeded and a value follows ("VA")
+	MetaStatusValue MetaStatus = "VA"
+
+	// MetaStatusMiss - the item was not found by a meta get ("EN")
+	MetaStatusMiss MetaStatus = "EN"
+
+	// MetaStatusNotStored - the item was not stored ("NS")
+	MetaStatusNotStored MetaStatus = "NS"
+
+	// MetaStatusExists - the cas unique did not match ("EX")
+	MetaStatusExists MetaStatus = "EX"
+
+	// MetaStatusNotFound - the item was not found ("NF")
+	MetaStatusNotFound MetaStatus = "NF"
+
+	// MetaStatusNoop - the no-op response ("MN")
+	MetaStatusNoop MetaStatus = "MN"
+)
+
+// metaStatusesByCode - all known meta statuses
+var metaStatusesByCode map[string]MetaStatus = map[string]MetaStatus{
+	string(MetaStatusSuccess):   MetaStatusSuccess,
+	string(MetaStatusValue):     MetaStatusValue,
+	string(MetaStatusMiss):      MetaStatusMiss,
+	string(MetaStatusNotStored): MetaStatusNotStored,
+	string(MetaStatusExists):    MetaStatusExists,
+	string(MetaStatusNotFound):  MetaStatusNotFound,
+	string(MetaStatusNoop):      MetaStatusNoop,
+}
+
+// meta flags returned without token
+const (
+	metaFlagWin        byte = 'W'
+	metaFlagStale      byte = 'X'
+	metaFlagAlreadyWon byte = 'Z'
+)
+
+// MetaFlags - builds the flags sent with a meta command
+type MetaFlags struct {
+	flags [][]byte
+}
+
+// NewMetaFlags - creates an empty set of meta flags
+func NewMetaFlags() *MetaFlags {
+
+	return &MetaFlags{
+		flags: [][]byte{},
+	}
+}
+
+// Flag - adds a flag without token
+func (m *MetaFlags) Flag(flag byte) *MetaFlags {
+
+	m.flags = append(m.flags, []byte{flag})
+
+	return m
+}
+
+// Token - adds a flag with a token
+func (m *MetaFlags) Token(flag byte, token []byte) *MetaFlags {
+
+	f := make([]byte, 0, len(token)+1)
+	f = append(f, flag)
+	f = append(f, token...)
+
+	m.flags = append(m.flags, f)
+
+	return m
+}
+
+// ReturnValue - returns the item's value (v)
+func (m *MetaFlags) ReturnValue() *MetaFlags {
+	return m.Flag('v')
+}
+
+// ReturnCAS - returns the item's cas unique (c)
+func (m *MetaFlags) ReturnCAS() *MetaFlags {
+	return m.Flag('c')
+}
+
+// ReturnClientFlags - returns the item's client flags (f)
+func (m *MetaFlags) ReturnClientFlags() *MetaFlags {
+	return m.Flag('f')
+}
+
+// ReturnTTL - returns the item's remaining time to live in seconds, -1 for unlimited (t)
+func (m *MetaFlags) ReturnTTL() *MetaFlags {
+	return m.Flag('t')
+}
+
+// ReturnLastAccess - returns the time in seconds since the item's last access (l)
+func (m *MetaFlags) ReturnLastAccess() *MetaFlags {
+	return m.Flag('l')
+}
+
+// ReturnHit - returns if the item was hit before (h)
+func (m *MetaFlags) ReturnHit() *MetaFlags {
+	return m.Flag('h')
+}
+
+// ReturnKey - returns the item's key (k)
+func (m *MetaFlags) ReturnKey() *MetaFlags {
+	return m.Flag('k')
+}
+
+// ReturnSize - returns the item's value size (s)
+func (m *MetaFlags) ReturnSize() *MetaFlags {
+	return m.Flag('s')
+}
+
+// NoLRUBump - does not bump the item in the LRU (u)
+func (m *MetaFlags) NoLRUBump() *MetaFlags {
+	return m.Flag('u')
+}
+
+// Invalidate - marks the item as stale instead of removing it or failing a cas (I)
+func (m *MetaFlags) Invalidate() *MetaFlags {
+	return m.Flag('I')
+}
+
+// Opaque - an opaque token reflected in the response (O)
+func (m *MetaFlags) Opaque(token []byte) *MetaFlags {
+	return m.Token('O', token)
+}
+
+// TTL - updates the item's time to live (T)
+func (m *MetaFlags) TTL(ttl []byte) *MetaFlags {
+	return m.Token('T', ttl)
+}
+
+// CompareCAS - only executes the command if the cas unique matches (C)
+func (m *MetaFlags) CompareCAS(casUnique uint64) *MetaFlags {
+	return m.Token('C', []byte(strconv.FormatUint(casUnique, 10)))
+}
+
+// ClientFlags - sets the item's client flags (F)
+func (m *MetaFlags) ClientFlags(flags uint32) *MetaFlags {
+	return m.Token('F', []byte(strconv.FormatUint(uint64(flags), 10)))
+}
+
+// Vivify - creates an empty item on miss with the given time to live, the caller receives the win flag (N)
+func (m *MetaFlags) Vivify(ttl []byte) *MetaFlags {
+	return m.Token('N', ttl)
+}
+
+// Recache - the caller receives the win flag if the remaining time to live is lower than the given one (R)
+func (m *MetaFlags) Recache(ttl []byte) *MetaFlags {
+	return m.Token('R', ttl)
+}
+
+// Mode - the command's mode (M), see the Meta*Mode constants
+func (m *MetaFlags) Mode(mode byte) *MetaFlags {
+	return m.Token('M', []byte{mode})
+}
+
+// Delta - the arithmetic delta (D)
+func (m *MetaFlags) Delta(delta uint64) *MetaFlags {
+	return m.Token('D', []byte(strconv.FormatUint(delta, 10)))
+}
+
+// InitialValue - the arithmetic initial value used with vivify (J)
+func (m *MetaFlags) InitialValue(value uint64) *MetaFlags {
+	return m.Token('J', []byte(strconv.FormatUint(value, 10)))
+}
+
+// meta command modes
+const (
+	// MetaSetModeAdd - stores only if the item does not exist
+	MetaSetModeAdd byte = 'E'
+
+	// MetaSetModeAppend - appends the value to the existing item
+	MetaSetModeAppend byte = 'A'
+
+	// MetaSetModePrepend - prepends the value to the existing item
+	MetaSetModePrepend byte = 'P'
+
+	// MetaSetModeReplace - stores only if the item exists
+	MetaSetModeReplace byte = 'R'
+
+	// MetaSetModeSet - stores the item (default)
+	MetaSetModeSet byte = 'S'
+
+	// MetaArithmeticModeIncrement - increments the value (default)
+	MetaArithmeticModeIncrement byte = 'I'
+
+	// MetaArithmeticModeDecrement - decrements the value
+	MetaArithmeticModeDecrement byte = 'D'
+)
+
+// MetaResponse - a parsed meta command response
+type MetaResponse struct {
+
+	// Status - the response status code
+	Status MetaStatus
+
+	// Flags - the returned flags indexed by the flag character (flags without token have empty values)
+	Flags map[byte][]byte
+
+	// Value - the item's value (only when the status is MetaStatusValue)
+	Value []byte
+}
+
+// Flag - returns the token of a returned flag
+func (r *MetaResponse) Flag(flag byte) ([]byte, bool) {
+
+	token, ok := r.Flags[flag]
+
+	return token, ok
+}
+
+// HasFlag - checks if a flag was returned
+func (r *MetaResponse) HasFlag(flag byte) bool {
+
+	_, ok := r.Flags[flag]
+
+	return ok
+}
+
+// CAS - returns the cas unique (requires ReturnCAS)
+func (r *MetaResponse) CAS() (uint64, bool) {
+
+	return r.uintFlag('c')
+}
+
+// ClientFlags - returns the client flags (requires ReturnClientFlags)
+func (r *MetaResponse) ClientFlags() (uint32, bool) {
+
+	value, ok := r.uintFlag('f')
+
+	return uint32(value), ok
+}
+
+// TTL - returns the remaining time to live in seconds, -1 for unlimited (requires ReturnTTL)
+func (r *MetaResponse) TTL() (int64, bool) {
+
+	token, ok := r.Flags['t']
+	if !ok {
+		return 0, false
+	}
+
+	value, err := strconv.ParseInt(string(token), 10, 64)
+	if err != nil {
+		return 0, false
+	}
+
+	return value, true
+}
+
+// LastAccess - returns the seconds since the last access (requires ReturnLastAccess)
+func (r *MetaResponse) LastAccess() (uint64, bool) {
+
+	return r.uintFlag('l')
+}
+
+// Opaque - returns the reflected opaque token
+func (r *MetaResponse) Opaque() ([]byte, bool) {
+
+	return r.Flag('O')
+}
+
+// Win - checks if this client won the right to recache the item
+func (r *MetaResponse) Win() bool {
+
+	return r.HasFlag(metaFlagWin)
+}
+
+// Stale - checks if the item is stale
+func (r *MetaResponse) Stale() bool {
+
+	return r.HasFlag(metaFlagStale)
+}
+
+// AlreadyWon - checks if another client already won the right to recache the item
+func (r *MetaResponse) AlreadyWon() bool {
+
+	return r.HasFlag(metaFlagAlreadyWon)
+}
+
+// uintFlag - parses an unsigned integer flag
+func (r *MetaResponse) uintFlag(flag byte) (uint64, bool) {
+
+	token, ok := r.Flags[flag]
+	if !ok {
+		return 0, false
+	}
+
+	value, err := strconv.ParseUint(string(token), 10, 64)
+	if err != nil {
+		return 0, false
+	}
+
+	return value, true
+}
+
+// renderMetaCmd - like Sprintf, but in bytes (the value is only written by the meta set command)
+func (z *Zencached) renderMetaCmd(cmd memcachedCommand, key, value []byte, flags *MetaFlags) []byte {
+
+	var length string
+	if bytes.Equal(cmd, ms) {
+		length = strconv.Itoa(len(value))
+	}
+
+	size := len(cmd) + len(key) + len(length) + len(value) + 2 + (len(doubleBreaks) * 2)
+	if flags != nil {
+		for i := 0; i < len(flags.flags); i++ {
+			size += len(flags.flags[i]) + 1
+		}
+	}
+
+	buffer := bytes.Buffer{}
+	buffer.Grow(size)
+	buffer.Write(cmd)
+
+	if len(key) > 0 {
+		buffer.WriteByte(whiteSpace)
+		buffer.Write(key)
+	}
+
+	if len(length) > 0 {
+		buffer.WriteByte(whiteSpace)
+		buffer.WriteString(length)
+	}
+
+	if flags != nil {
+		for i := 0; i < len(flags.flags); i++ {
+			buffer.WriteByte(whiteSpace)
+			buffer.Write(flags.flags[i])
+		}
+	}
+
+	buffer.Write(doubleBreaks)
+
+	if len(length) > 0 {
+		buffer.Write(value)
+		buffer.Write(doubleBreaks)
+	}
+
+	return buffer.Bytes()
+}
+
+// readMetaResponse - reads and parses a meta command response
+func (z *Zencached) readMetaResponse(telnetConn *Telnet, cmd memcachedCommand) (*MetaResponse, error) {
+
+	line, err := telnetConn.ReadLine()
+	if err != nil {
+		return nil, err
+	}
+
+	fields := bytes.Fields(line)
+	if len(fields) == 0 {
+		telnetConn.markBroken()
+		return nil, fmt.Errorf("memcached operation error on command %s: empty response", cmd)
+	}
+
+	status, ok := metaStatusesByCode[string(fields[0])]
+	if !ok {
+		return nil, responseError(telnetConn, cmd, line)
+	}
+
+	response := &MetaResponse{
+		Status: status,
+		Flags:  map[byte][]byte{},
+	}
+
+	fields = fields[1:]
+
+	if status == MetaStatusValue {
+
+		if len(fields) == 0 {
+			telnetConn.markBroken()
+			return nil, fmt.Errorf("no value length: %s", line)
+		}
+
+		length, err := strconv.Atoi(string(fields[0]))
+		if err != nil || length < 0 {
+			telnetConn.markBroken()
+			return nil, fmt.Errorf("invalid value length: %s", line)
+		}
+
+		data, err := telnetConn.ReadFull(length + len(doubleBreaks))
+		if err != nil {
+			return nil, err
+		}
+
+		if !bytes.HasSuffix(data, doubleBreaks) {
+			telnetConn.markBroken()
+			return nil, fmt.Errorf("value length mismatch: %s", line)
+		}
+
+		response.Value = data[:length]
+		fields = fields[1:]
+	}
+
+	for i := 0; i < len(fields); i++ {
+		response.Flags[fields[i][0]] = fields[i][1:]
+	}
+
+	return response, nil
+}
+
+// baseMeta - the base meta operation
+func (z *Zencached) baseMeta(telnetConn *Telnet, cmd memcachedCommand, key, value []byte, flags *MetaFlags) (*MetaResponse, error) {
+
+	if err := z.checkTextProtocol(cmd); err != nil {
+		return nil, err
+	}
+
+	if z.enableMetrics {
+		z.countOperation(telnetConn.GetHost(), cmd)
+	}
+
+	err := z.executeSend(telnetConn, cmd, z.renderMetaCmd(cmd, key, value, flags))
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := z.readMetaResponse(telnetConn, cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	if z.enableMetrics && response.Status != MetaStatusNoop {
+		z.countHitOrMiss(telnetConn.GetHost(), cmd, response.Status == MetaStatusSuccess || response.Status == MetaStatusValue)
+	}
+
+	return response, nil
+}
+
+// MetaGet - performs a meta get operation (flags may be nil)
+func (z *Zencached) MetaGet(routerHash, key []byte, flags *MetaFlags) (*MetaResponse, error) {
+
+	return z.MetaGetContext(context.Background(), routerHash, key, flags)
+}
+
+// MetaGetContext - same as MetaGet, but using the context to cancel the operation
+func (z *Zencached) MetaGetContext(ctx context.Context, routerHash, key []byte, flags *MetaFlags) (*MetaResponse, error) {
+
+	telnetConn, index, err := z.GetTelnetConnectionContext(ctx, routerHash, key)
+	if err != nil {
+		return nil, err
+	}
+	defer z.ReturnTelnetConnection(telnetConn, index)
+
+	return z.baseMeta(telnetConn, mg, key, nil, flags)
+}
+
+// MetaSet - performs a meta set operation (flags may be nil)
+func (z *Zencached) MetaSet(routerHash, key, value []byte, flags *MetaFlags) (*MetaResponse, error) {
+
+	return z.MetaSetContext(context.Background(), routerHash, key, value, flags)
+}
+
+// MetaSetContext - same as MetaSet, but using the context to cancel the operation
+func (z *Zencached) MetaSetContext(ctx context.Context, routerHash, key, value []byte, flags *MetaFlags) (*MetaResponse, error) {
+
+	telnetConn, index, err := z.GetTelnetConnectionContext(ctx, routerHash, key)
+	if err != nil {
+		return nil, err
+	}
+	defer z.ReturnTelnetConnection(telnetConn, index)
+
+	return z.baseMeta(telnetConn, ms, key, value, flags)
+}
+
+// MetaDelete - performs a meta delete operation (flags may be nil)
+func (z *Zencached) MetaDelete(routerHash, key []byte, flags *MetaFlags) (*MetaResponse, error) {
+
+	return z.MetaDeleteContext(context.Background(), routerHash, key, flags)
+}
+
+// MetaDeleteContext - same as MetaDelete, but using the context to cancel the operation
+func (z *Zencached) MetaDeleteContext(ctx context.Context, routerHash, key []byte, flags *MetaFlags) (*MetaResponse, error) {
+
+	telnetConn, index, err := z.GetTelnetConnectionContext(ctx, routerHash, key)
+	if err != nil {
+		return nil, err
+	}
+	defer z.ReturnTelnetConnection(telnetConn, index)
+
+	return z.baseMeta(telnetConn, md, key, nil, flags)
+}
+
+// MetaArithmetic - performs a meta arithmetic operation (flags may be nil)
+func (z *Zencached) MetaArithmetic(routerHash, key []byte, flags *MetaFlags) (*MetaResponse, error) {
+
+	return z.MetaArithmeticContext(context.Background(), routerHash, key, flags)
+}
+
+// MetaArithmeticContext - same as MetaArithmetic, but using the context to cancel the operation
+func (z *Zencached) MetaArithmeticContext(ctx context.Context, routerHash, key []byte, flags *MetaFlags) (*MetaResponse, error) {
+
+	telnetConn, index, err := z.GetTelnetConnectionContext(ctx, routerHash, key)
+	if err != nil {
+		return nil, err
+	}
+	defer z.ReturnTelnetConnection(telnetConn, index)
+
+	return z.baseMeta(telnetConn, ma, key, nil, flags)
+}
+
+// MetaNoop - performs a meta no-op operation on the specified node
+func (z *Zencached) MetaNoop(nodeIndex int) error {
+
+	return z.MetaNoopContext(context.Background(), nodeIndex)
+}
+
+// MetaNoopContext - same as MetaNoop, but using the context to cancel the operation
+func (z *Zencached) MetaNoopContext(ctx context.Context, nodeIndex int) error {
+
+	telnetConn, err := z.GetTelnetConnByNodeIndexContext(ctx, nodeIndex)
+	if err != nil {
+		return err
+	}
+	defer z.ReturnTelnetConnection(telnetConn, nodeIndex)
+
+	response, err := z.baseMeta(telnetConn, mn, nil, nil, nil)
+	if err != nil {
+		return err
+	}
+
+	if response.Status != MetaStatusNoop {
+		return fmt.Errorf("memcached operation error on command %s: unexpected status %s", mn, response.Status)
+	}
+
+	return nil
+}
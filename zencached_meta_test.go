@@ -0,0 +1,157 @@
+package zencached_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/uol/zencached"
+)
+
+//
+// These tests requires a memcached supporting the meta commands (1.6+).
+//
+
+// TestMetaSetAndGet - tests the meta set and meta get commands
+func TestMetaSetAndGet(t *testing.T) {
+
+	z := createZencached(nil)
+	defer z.Shutdown()
+
+	key := []byte("meta")
+	value := []byte("meta-value\r\nEND\r\n")
+
+	response, err := z.MetaSet(nil, key, value, zencached.NewMetaFlags().TTL(defaultTTL).ClientFlags(30).ReturnCAS())
+	if !assert.NoError(t, err, "unexpected error on meta set") {
+		return
+	}
+
+	if !assert.Equal(t, zencached.MetaStatusSuccess, response.Status, "expected the item to be stored") {
+		return
+	}
+
+	casUnique, ok := response.CAS()
+	if !assert.True(t, ok, "expected the cas unique to be returned") {
+		return
+	}
+
+	response, err = z.MetaGet(nil, key, zencached.NewMetaFlags().ReturnValue().ReturnCAS().ReturnClientFlags().ReturnTTL().ReturnKey().Opaque([]byte("op1")))
+	if !assert.NoError(t, err, "unexpected error on meta get") {
+		return
+	}
+
+	if !assert.Equal(t, zencached.MetaStatusValue, response.Status, "expected a value") {
+		return
+	}
+
+	assert.Equal(t, value, response.Value, "expected the same value")
+
+	getCAS, _ := response.CAS()
+	assert.Equal(t, casUnique, getCAS, "expected the same cas unique")
+
+	flags, _ := response.ClientFlags()
+	assert.Equal(t, uint32(30), flags, "expected the same client flags")
+
+	ttl, ok := response.TTL()
+	assert.True(t, ok && ttl > 0 && ttl <= 60, "expected a positive ttl")
+
+	returnedKey, _ := response.Flag('k')
+	assert.Equal(t, key, returnedKey, "expected the same key")
+
+	opaque, _ := response.Opaque()
+	assert.Equal(t, []byte("op1"), opaque, "expected the same opaque")
+
+	response, err = z.MetaSet(nil, key, []byte("other"), zencached.NewMetaFlags().CompareCAS(casUnique+1000))
+	if !assert.NoError(t, err, "unexpected error on meta set") {
+		return
+	}
+
+	assert.Equal(t, zencached.MetaStatusExists, response.Status, "expected a cas mismatch")
+
+	response, err = z.MetaSet(nil, key, []byte("other"), zencached.NewMetaFlags().Mode(zencached.MetaSetModeAdd))
+	if !assert.NoError(t, err, "unexpected error on meta set") {
+		return
+	}
+
+	assert.Equal(t, zencached.MetaStatusNotStored, response.Status, "expected the add to fail")
+}
+
+// TestMetaDelete - tests the meta delete command
+func TestMetaDelete(t *testing.T) {
+
+	z := createZencached(nil)
+	defer z.Shutdown()
+
+	key := []byte("meta-delete")
+
+	_, err := z.MetaSet(nil, key, []byte("value"), nil)
+	if err != nil {
+		panic(err)
+	}
+
+	response, err := z.MetaDelete(nil, key, nil)
+	if !assert.NoError(t, err, "unexpected error on meta delete") {
+		return
+	}
+
+	assert.Equal(t, zencached.MetaStatusSuccess, response.Status, "expected the item to be deleted")
+
+	response, err = z.MetaDelete(nil, key, nil)
+	if !assert.NoError(t, err, "unexpected error on meta delete") {
+		return
+	}
+
+	assert.Equal(t, zencached.MetaStatusNotFound, response.Status, "expected the item to not be found")
+
+	response, err = z.MetaGet(nil, key, zencached.NewMetaFlags().ReturnValue())
+	if !assert.NoError(t, err, "unexpected error on meta get") {
+		return
+	}
+
+	assert.Equal(t, zencached.MetaStatusMiss, response.Status, "expected a miss")
+}
+
+// TestMetaArithmetic - tests the meta arithmetic command
+func TestMetaArithmetic(t *testing.T) {
+
+	z := createZencached(nil)
+	defer z.Shutdown()
+
+	key := []byte("meta-counter")
+
+	_, err := z.MetaDelete(nil, key, nil)
+	if err != nil {
+		panic(err)
+	}
+
+	response, err := z.MetaArithmetic(nil, key, nil)
+	if !assert.NoError(t, err, "unexpected error on meta arithmetic") {
+		return
+	}
+
+	assert.Equal(t, zencached.MetaStatusNotFound, response.Status, "expected the counter to not be found")
+
+	response, err = z.MetaArithmetic(nil, key, zencached.NewMetaFlags().Vivify(defaultTTL).InitialValue(10).ReturnValue())
+	if !assert.NoError(t, err, "unexpected error on meta arithmetic") {
+		return
+	}
+
+	assert.Equal(t, []byte("10"), response.Value, "expected the initial value")
+
+	response, err = z.MetaArithmetic(nil, key, zencached.NewMetaFlags().Mode(zencached.MetaArithmeticModeDecrement).Delta(4).ReturnValue())
+	if !assert.NoError(t, err, "unexpected error on meta arithmetic") {
+		return
+	}
+
+	assert.Equal(t, []byte("6"), response.Value, "expected the decremented value")
+}
+
+// TestMetaNoop - tests the meta no-op command on all nodes
+func TestMetaNoop(t *testing.T) {
+
+	z := createZencached(nil)
+	defer z.Shutdown()
+
+	for i := 0; i < numNodes; i++ {
+		assert.NoErrorf(t, z.MetaNoop(i), "unexpected error on node %d", i)
+	}
+}
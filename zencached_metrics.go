@@ -8,12 +8,20 @@ package zencached
 const (
 	metricNodeDistribution      string = "zencached.node.distribution.count"
 	metricNodeConnAvailableTime string = "zencached.node.conn.available.time"
+	metricNodeConnTimeout       string = "zencached.node.conn.timeout"
+	metricNodeConnRecycled      string = "zencached.node.conn.recycled"
+	metricNodeEjected           string = "zencached.node.ejected"
+	metricNodeReadmitted        string = "zencached.node.readmitted"
+	metricNodeCircuitState      string = "zencached.node.circuit.state"
+	metricNodeCircuitRejected   string = "zencached.node.circuit.rejected"
+	metricClusterReadRepair     string = "zencached.cluster.read.repair"
 	metricOperationCount        string = "zencached.operation.count"
 	metricOperationTime         string = "zencached.operation.time"
 	metricCacheMiss             string = "zencached.cache.miss"
 	metricCacheHit              string = "zencached.cache.hit"
 	tagNodeName                 string = "node"
 	tagOperationName            string = "operation"
+	tagCircuitState             string = "state"
 )
 
 // MetricsCollector - the interface
@@ -0,0 +1,317 @@
+package zencached
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/uol/logh"
+)
+
+//
+// The node pools and the runtime changes of the node set. Each change creates a new
+// node set, the operations in flight keep using the set loaded when they started.
+//
+
+// ErrNodeRemoved - returned when the node is removed while waiting for one of its connections
+var ErrNodeRemoved error = errors.New("node removed from the pool")
+
+// nodePool - the connections and the failure state of a node
+type nodePool struct {
+	node     Node
+	conns    chan *Telnet
+	removed  chan struct{}
+	failures uint32
+	ejected  uint32
+	breaker  *circuitBreaker
+}
+
+// nodeSet - an immutable snapshot of the nodes, their pools and the router
+type nodeSet struct {
+	nodes  []Node
+	pools  []*nodePool
+	router Router
+}
+
+// newNodePool - creates the node pool with all its connections (not connected)
+func (z *Zencached) newNodePool(node Node) (*nodePool, error) {
+
+	pool := &nodePool{
+		node:    node,
+		conns:   make(chan *Telnet, z.configuration.NumConnectionsPerNode),
+		removed: make(chan struct{}),
+	}
+
+	if z.configuration.CircuitBreakerThreshold > 0 {
+		pool.breaker = newCircuitBreaker(z.configuration.CircuitBreakerThreshold, z.configuration.CircuitBreakerOpenTimeout)
+	}
+
+	for c := 0; c < z.configuration.NumConnectionsPerNode; c++ {
+		telnetConn, err := NewTelnet(&pool.node, &z.configuration.TelnetConfiguration)
+		if err != nil {
+			return nil, err
+		}
+
+		telnetConn.pool = pool
+		telnetConn.breaker = pool.breaker
+		pool.conns <- telnetConn
+	}
+
+	return pool, nil
+}
+
+// newNodeSet - creates a node set routing the keys among the specified nodes
+func (z *Zencached) newNodeSet(nodes []Node, pools []*nodePool) *nodeSet {
+
+	return &nodeSet{
+		nodes:  nodes,
+		pools:  pools,
+		router: newRouter(z.configuration, nodes),
+	}
+}
+
+// loadNodes - returns the current node set
+func (z *Zencached) loadNodes() *nodeSet {
+
+	return z.nodes.Load().(*nodeSet)
+}
+
+// route - returns the index of the node owning the key (a custom router may already be rebuilt with another node set)
+func (s *nodeSet) route(routerHash []byte) int {
+
+	return s.router.Route(routerHash) % len(s.pools)
+}
+
+// replicas - returns the distinct node indexes of the key replicas (the route successors if the router does not choose the replicas)
+func (s *nodeSet) replicas(routerHash []byte, n int) []int {
+
+	if n > len(s.pools) {
+		n = len(s.pools)
+	}
+
+	if router, ok := s.router.(ReplicaRouter); ok {
+
+		routed := router.RouteReplicas(routerHash, n)
+
+		indexes := make([]int, len(routed))
+		for i := 0; i < len(routed); i++ {
+			indexes[i] = routed[i] % len(s.pools)
+		}
+
+		return indexes
+	}
+
+	primary := s.route(routerHash)
+
+	indexes := make([]int, n)
+	for i := 0; i < n; i++ {
+		indexes[i] = (primary + i) % len(s.pools)
+	}
+
+	return indexes
+}
+
+// indexOf - returns the index of the node with the same address or -1 if not found
+func (s *nodeSet) indexOf(node *Node) int {
+
+	address := nodeAddress(node)
+
+	for i := 0; i < len(s.nodes); i++ {
+		if nodeAddress(&s.nodes[i]) == address {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// Nodes - returns the current nodes
+func (z *Zencached) Nodes() []Node {
+
+	nodes := z.loadNodes().nodes
+
+	result := make([]Node, len(nodes))
+	copy(result, nodes)
+
+	return result
+}
+
+// AddNode - adds a new node to the pool, rebuilding the router
+func (z *Zencached) AddNode(node Node) error {
+
+	z.nodesMutex.Lock()
+	defer z.nodesMutex.Unlock()
+
+	err := z.checkNodesChange()
+	if err != nil {
+		return err
+	}
+
+	current := z.loadNodes()
+
+	if current.indexOf(&node) >= 0 {
+		return fmt.Errorf("node already added: %s", nodeAddress(&node))
+	}
+
+	pool, err := z.newNodePool(node)
+	if err != nil {
+		return err
+	}
+
+	nodes := append(append([]Node{}, current.nodes...), node)
+	pools := append(append([]*nodePool{}, current.pools...), pool)
+
+	if logh.InfoEnabled {
+		z.logger.Info().Msgf("adding node: %s", nodeAddress(&node))
+	}
+
+	z.storeNodes(z.newNodeSet(nodes, pools))
+
+	return nil
+}
+
+// RemoveNode - removes a node from the pool, rebuilding the router and closing its connections after their operations
+func (z *Zencached) RemoveNode(node Node) error {
+
+	z.nodesMutex.Lock()
+	defer z.nodesMutex.Unlock()
+
+	err := z.checkNodesChange()
+	if err != nil {
+		return err
+	}
+
+	current := z.loadNodes()
+
+	index := current.indexOf(&node)
+	if index < 0 {
+		return fmt.Errorf("node not found: %s", nodeAddress(&node))
+	}
+
+	if len(current.nodes) == 1 {
+		return fmt.Errorf("the last node can not be removed: %s", nodeAddress(&node))
+	}
+
+	err = z.configuration.checkReplication(len(current.nodes) - 1)
+	if err != nil {
+		return err
+	}
+
+	nodes := append(append([]Node{}, current.nodes[:index]...), current.nodes[index+1:]...)
+	pools := append(append([]*nodePool{}, current.pools[:index]...), current.pools[index+1:]...)
+
+	if logh.InfoEnabled {
+		z.logger.Info().Msgf("removing node: %s", nodeAddress(&node))
+	}
+
+	z.storeNodes(z.newNodeSet(nodes, pools))
+	z.drainPool(current.pools[index])
+
+	return nil
+}
+
+// ReplaceNodes - replaces all nodes, keeping the connections of the nodes with the same address
+func (z *Zencached) ReplaceNodes(nodes []Node) error {
+
+	if len(nodes) == 0 {
+		return fmt.Errorf("no nodes configured")
+	}
+
+	z.nodesMutex.Lock()
+	defer z.nodesMutex.Unlock()
+
+	err := z.checkNodesChange()
+	if err != nil {
+		return err
+	}
+
+	err = z.configuration.checkReplication(len(nodes))
+	if err != nil {
+		return err
+	}
+
+	current := z.loadNodes()
+
+	kept := make([]bool, len(current.pools))
+	pools := make([]*nodePool, len(nodes))
+
+	for i := 0; i < len(nodes); i++ {
+
+		for j := 0; j < i; j++ {
+			if nodeAddress(&nodes[i]) == nodeAddress(&nodes[j]) {
+				return fmt.Errorf("duplicated node: %s", nodeAddress(&nodes[i]))
+			}
+		}
+
+		index := current.indexOf(&nodes[i])
+		if index >= 0 {
+			kept[index] = true
+			pools[i] = current.pools[index]
+			continue
+		}
+
+		pools[i], err = z.newNodePool(nodes[i])
+		if err != nil {
+			return err
+		}
+	}
+
+	if logh.InfoEnabled {
+		z.logger.Info().Msgf("replacing %d nodes by %d nodes", len(current.nodes), len(nodes))
+	}
+
+	z.storeNodes(z.newNodeSet(append([]Node{}, nodes...), pools))
+
+	for i := 0; i < len(current.pools); i++ {
+		if !kept[i] {
+			z.drainPool(current.pools[i])
+		}
+	}
+
+	return nil
+}
+
+// checkNodesChange - returns an error if the instance is shutting down or uses a router instance, the operations
+// in flight would route using the new nodes (must be called holding the nodes mutex)
+func (z *Zencached) checkNodesChange() error {
+
+	if atomic.LoadUint32(&z.shuttingDown) == 1 {
+		return fmt.Errorf("the nodes can not be changed while shutting down")
+	}
+
+	if z.configuration.Router != nil {
+		return fmt.Errorf("the nodes of a router instance can not be changed, use a router factory")
+	}
+
+	return nil
+}
+
+// storeNodes - replaces the current node set and its failover routing (must be called holding the nodes mutex)
+func (z *Zencached) storeNodes(nodes *nodeSet) {
+
+	z.nodes.Store(nodes)
+	z.storeFailover(nodes)
+}
+
+// drainPool - wakes up the operations waiting for the removed node and closes its connections when returned
+// (must be called holding the nodes mutex)
+func (z *Zencached) drainPool(pool *nodePool) {
+
+	close(pool.removed)
+
+	z.backgroundTasks.Add(1)
+
+	go func() {
+
+		defer z.backgroundTasks.Done()
+
+		for c := 0; c < z.configuration.NumConnectionsPerNode; c++ {
+			telnetConn := <-pool.conns
+			telnetConn.Close()
+		}
+
+		if logh.InfoEnabled {
+			z.logger.Info().Msgf("all connections closed from the removed node: %s", nodeAddress(&pool.node))
+		}
+	}()
+}
@@ -0,0 +1,324 @@
+package zencached_test
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/uol/zencached"
+)
+
+// countIndex - counts the occurrences of the index
+func countIndex(indexes []int, index int) int {
+
+	count := 0
+	for _, i := range indexes {
+		if i == index {
+			count++
+		}
+	}
+
+	return count
+}
+
+// TestAddNode - tests if the keys are routed to an added node
+func TestAddNode(t *testing.T) {
+
+	server1, node1 := createMissServer("")
+	defer server1.listener.Close()
+
+	server2, node2 := createMissServer("")
+	defer server2.listener.Close()
+
+	z := createLocalZencached([]zencached.Node{node1}, nil, withRouting(zencached.KetamaRouting))
+	defer z.Shutdown()
+
+	keys := createKeys(100)
+
+	if !assert.Equal(t, 100, countIndex(routeKeys(z, keys), 0), "expected all keys on the single node") {
+		return
+	}
+
+	if !assert.NoError(t, z.AddNode(node2), "unexpected error adding the node") {
+		return
+	}
+
+	assert.Equal(t, []zencached.Node{node1, node2}, z.Nodes(), "expected the added node")
+	assert.True(t, countIndex(routeKeys(z, keys), 1) > 0, "expected some keys on the added node")
+	assert.Error(t, z.AddNode(node2), "expected an error adding the same node again")
+
+	for _, key := range keys {
+		_, found, err := z.Get(nil, key)
+		if !assert.NoError(t, err, "unexpected error on get") || !assert.False(t, found, "expected a miss") {
+			return
+		}
+	}
+
+	assert.True(t, atomic.LoadUint32(&server2.commands) > 0, "expected commands on the added node")
+}
+
+// TestRemoveNodeWithOperationInFlight - tests if a removed node finishes its operations before closing its connections
+func TestRemoveNodeWithOperationInFlight(t *testing.T) {
+
+	server1, node1 := createScriptedServer(func(conn net.Conn, connNumber int, line string) bool {
+
+		if !strings.HasPrefix(line, "get ") {
+			return false
+		}
+
+		<-time.After(200 * time.Millisecond)
+		conn.Write([]byte("END\r\n"))
+
+		return true
+	})
+	defer server1.listener.Close()
+
+	server2, node2 := createMissServer("")
+	defer server2.listener.Close()
+
+	z := createLocalZencached([]zencached.Node{node1, node2}, nil, withRouting(zencached.KetamaRouting))
+	defer z.Shutdown()
+
+	keys := createKeys(100)
+	indexes := routeKeys(z, keys)
+
+	var key []byte
+	for i := 0; i < len(keys); i++ {
+		if indexes[i] == 0 {
+			key = keys[i]
+			break
+		}
+	}
+
+	if !assert.NotNil(t, key, "expected a key on the first node") {
+		return
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, _, err := z.Get(nil, key)
+		done <- err
+	}()
+
+	<-time.After(50 * time.Millisecond)
+
+	if !assert.NoError(t, z.RemoveNode(node1), "unexpected error removing the node") {
+		return
+	}
+
+	assert.Equal(t, []zencached.Node{node2}, z.Nodes(), "expected only the second node")
+	assert.Equal(t, 100, countIndex(routeKeys(z, keys), 0), "expected all keys on the remaining node")
+	assert.Equal(t, uint32(0), atomic.LoadUint32(&server1.disconnections), "expected the connection in use to be kept")
+
+	assert.NoError(t, <-done, "expected the operation in flight to finish")
+
+	<-time.After(100 * time.Millisecond)
+
+	assert.Equal(t, uint32(1), atomic.LoadUint32(&server1.disconnections), "expected the removed node connection to be closed")
+	assert.Equal(t, uint32(1), atomic.LoadUint32(&server1.commands), "expected no more commands on the removed node")
+	assert.Error(t, z.RemoveNode(node1), "expected an error removing an unknown node")
+	assert.Error(t, z.RemoveNode(node2), "expected an error removing the last node")
+}
+
+// TestRemoveNodeWakesUpWaitingOperations - tests if the operations waiting for a removed node connection are rerouted
+func TestRemoveNodeWakesUpWaitingOperations(t *testing.T) {
+
+	server1, node1 := createMissServer("")
+	defer server1.listener.Close()
+
+	server2, node2 := createMissServer("")
+	defer server2.listener.Close()
+
+	z := createLocalZencached([]zencached.Node{node1, node2}, nil, withRouting(zencached.KetamaRouting))
+	defer z.Shutdown()
+
+	keys := createKeys(100)
+	indexes := routeKeys(z, keys)
+
+	var key []byte
+	for i := 0; i < len(keys); i++ {
+		if indexes[i] == 0 {
+			key = keys[i]
+			break
+		}
+	}
+
+	if !assert.NotNil(t, key, "expected a key on the first node") {
+		return
+	}
+
+	telnetConn := z.GetTelnetConnByNodeIndex(0)
+
+	type acquired struct {
+		conn  *zencached.Telnet
+		index int
+		err   error
+	}
+
+	done := make(chan acquired, 1)
+	go func() {
+		conn, index, err := z.GetTelnetConnectionContext(context.Background(), nil, key)
+		done <- acquired{conn, index, err}
+	}()
+
+	<-time.After(50 * time.Millisecond)
+
+	if !assert.NoError(t, z.RemoveNode(node1), "unexpected error removing the node") {
+		return
+	}
+
+	select {
+	case result := <-done:
+		if assert.NoError(t, result.err, "expected the operation to be rerouted") {
+			assert.Equal(t, node2.Port, result.conn.GetPort(), "expected a connection from the remaining node")
+			z.ReturnTelnetConnection(result.conn, result.index)
+		}
+	case <-time.After(time.Second):
+		t.Error("expected the waiting operation to wake up")
+	}
+
+	z.ReturnTelnetConnection(telnetConn, 0)
+}
+
+// TestReplaceNodes - tests if the kept nodes keep their connections and the removed ones are closed
+func TestReplaceNodes(t *testing.T) {
+
+	server1, node1 := createMissServer("")
+	defer server1.listener.Close()
+
+	server2, node2 := createMissServer("")
+	defer server2.listener.Close()
+
+	server3, node3 := createMissServer("")
+	defer server3.listener.Close()
+
+	z := createLocalZencached([]zencached.Node{node1, node2}, nil, withRouting(zencached.KetamaRouting))
+	defer z.Shutdown()
+
+	keys := createKeys(100)
+
+	for _, key := range keys {
+		_, _, err := z.Get(nil, key)
+		if !assert.NoError(t, err, "unexpected error on get") {
+			return
+		}
+	}
+
+	if !assert.NoError(t, z.ReplaceNodes([]zencached.Node{node2, node3}), "unexpected error replacing the nodes") {
+		return
+	}
+
+	assert.Equal(t, []zencached.Node{node2, node3}, z.Nodes(), "expected the new nodes")
+
+	for _, key := range keys {
+		_, _, err := z.Get(nil, key)
+		if !assert.NoError(t, err, "unexpected error on get") {
+			return
+		}
+	}
+
+	<-time.After(100 * time.Millisecond)
+
+	assert.Equal(t, uint32(1), atomic.LoadUint32(&server1.disconnections), "expected the removed node connection to be closed")
+	assert.Equal(t, uint32(1), atomic.LoadUint32(&server2.connections), "expected the kept node connection to be reused")
+	assert.True(t, atomic.LoadUint32(&server3.commands) > 0, "expected commands on the new node")
+	assert.Error(t, z.ReplaceNodes(nil), "expected an error replacing by no nodes")
+}
+
+// panicsByNodeIndex - checks if getting the connection by node index panics
+func panicsByNodeIndex(z *zencached.Zencached, index int) (panicked bool) {
+
+	defer func() {
+		panicked = recover() != nil
+	}()
+
+	z.GetTelnetConnByNodeIndex(index)
+
+	return
+}
+
+// TestGetTelnetConnByNodeIndexWithRemovedNode - tests the node index bounds and the index waiting for a removed node
+func TestGetTelnetConnByNodeIndexWithRemovedNode(t *testing.T) {
+
+	server1, node1 := createMissServer("")
+	defer server1.listener.Close()
+
+	server2, node2 := createMissServer("")
+	defer server2.listener.Close()
+
+	z := createLocalZencached([]zencached.Node{node1, node2}, nil, withRouting(zencached.KetamaRouting))
+	defer z.Shutdown()
+
+	assert.True(t, panicsByNodeIndex(z, -1), "expected a panic for a negative index")
+	assert.True(t, panicsByNodeIndex(z, 2), "expected a panic for an index out of range")
+
+	_, err := z.GetTelnetConnByNodeIndexContext(context.Background(), 2)
+	assert.Error(t, err, "expected an error for an index out of range")
+
+	telnetConn := z.GetTelnetConnByNodeIndex(0)
+
+	done := make(chan *zencached.Telnet, 1)
+	go func() {
+		done <- z.GetTelnetConnByNodeIndex(0)
+	}()
+
+	<-time.After(50 * time.Millisecond)
+
+	if !assert.NoError(t, z.RemoveNode(node1), "unexpected error removing the node") {
+		return
+	}
+
+	select {
+	case conn := <-done:
+		if assert.NotNil(t, conn, "expected a connection from the new node set") {
+			assert.Equal(t, node2.Port, conn.GetPort(), "expected a connection from the node now on the index")
+			z.ReturnTelnetConnection(conn, 0)
+		}
+	case <-time.After(time.Second):
+		t.Error("expected the waiting call to wake up")
+	}
+
+	z.ReturnTelnetConnection(telnetConn, 0)
+}
+
+// TestGetMultiWithRemovedNode - tests if the keys of a node removed while waiting are routed again
+func TestGetMultiWithRemovedNode(t *testing.T) {
+
+	server1, node1 := createMissServer("")
+	defer server1.listener.Close()
+
+	server2, node2 := createMissServer("")
+	defer server2.listener.Close()
+
+	z := createLocalZencached([]zencached.Node{node1, node2}, nil, withRouting(zencached.KetamaRouting))
+	defer z.Shutdown()
+
+	telnetConn := z.GetTelnetConnByNodeIndex(0)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := z.GetMulti(nil, createKeys(20))
+		done <- err
+	}()
+
+	<-time.After(50 * time.Millisecond)
+
+	if !assert.NoError(t, z.RemoveNode(node1), "unexpected error removing the node") {
+		return
+	}
+
+	select {
+	case err := <-done:
+		assert.NoError(t, err, "expected the keys routed to the remaining node")
+	case <-time.After(time.Second):
+		t.Error("expected the waiting multi get to wake up")
+	}
+
+	z.ReturnTelnetConnection(telnetConn, 0)
+
+	assert.True(t, atomic.LoadUint32(&server2.commands) >= 2, "expected the keys of both nodes on the remaining node")
+}
@@ -2,6 +2,7 @@ package zencached
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"strconv"
 	"time"
@@ -19,25 +20,30 @@ const (
 	lineBreaksR byte = '\r'
 	lineBreaksN byte = '\n'
 	whiteSpace  byte = ' '
-	zero        byte = '0'
 )
 
 // memcached responses
 var (
 	doubleBreaks []byte = []byte{lineBreaksR, lineBreaksN}
 	// responses
-	mcrValue     []byte = []byte("VALUE") // the only prefix
+	mcrValue     []byte = []byte("VALUE")
 	mcrStored    []byte = []byte("STORED")
 	mcrNotStored []byte = []byte("NOT_STORED")
 	mcrEnd       []byte = []byte("END")
 	mcrNotFound  []byte = []byte("NOT_FOUND")
 	mcrDeleted   []byte = []byte("DELETED")
-
-	// response set
-	mcrStoredResponseSet      [][]byte = [][]byte{mcrStored, mcrNotStored}
-	mcrGetCheckResponseSet    [][]byte = [][]byte{mcrEnd}
-	mcrGetCheckEndResponseSet [][]byte = [][]byte{mcrValue, mcrEnd}
-	mcrDeletedResponseSet     [][]byte = [][]byte{mcrDeleted, mcrNotFound}
+	mcrExists    []byte = []byte("EXISTS")
+	mcrTouched   []byte = []byte("TOUCHED")
+
+	// error responses (the response is complete, the connection is still usable)
+	mcrError       []byte = []byte("ERROR")
+	mcrClientError []byte = []byte("CLIENT_ERROR")
+	mcrServerError []byte = []byte("SERVER_ERROR")
+
+	// response set (positive and negative responses)
+	mcrStoredResponseSet  [][]byte = [][]byte{mcrStored, mcrNotStored}
+	mcrDeletedResponseSet [][]byte = [][]byte{mcrDeleted, mcrNotFound}
+	mcrTouchedResponseSet [][]byte = [][]byte{mcrTouched, mcrNotFound}
 )
 
 // memcachedCommand type
@@ -50,11 +56,74 @@ var (
 	// Set - sets a key if it exists or not
 	Set memcachedCommand = memcachedCommand("set")
 
+	// Replace - replaces a key only if it exists
+	Replace memcachedCommand = memcachedCommand("replace")
+
+	// Append - appends the value to an existing key
+	Append memcachedCommand = memcachedCommand("append")
+
+	// Prepend - prepends the value to an existing key
+	Prepend memcachedCommand = memcachedCommand("prepend")
+
+	// storageCommands - all commands accepted by the storage operations
+	storageCommands []memcachedCommand = []memcachedCommand{Add, Set, Replace, Append, Prepend}
+
 	// get - return a key if it exists or not
 	get memcachedCommand = memcachedCommand("get")
 
+	// gets - return a key and its cas unique if it exists or not
+	gets memcachedCommand = memcachedCommand("gets")
+
+	// cas - stores a key only if it was not modified since the last fetch
+	cas memcachedCommand = memcachedCommand("cas")
+
 	// delete - return a key if it exists or not
 	delete memcachedCommand = memcachedCommand("delete")
+
+	// incr - increments a numeric value
+	incr memcachedCommand = memcachedCommand("incr")
+
+	// decr - decrements a numeric value (never below zero)
+	decr memcachedCommand = memcachedCommand("decr")
+
+	// touch - updates the expiration time of a key
+	touch memcachedCommand = memcachedCommand("touch")
+
+	// gat - return a key updating its expiration time
+	gat memcachedCommand = memcachedCommand("gat")
+
+	// gats - return a key and its cas unique updating its expiration time
+	gats memcachedCommand = memcachedCommand("gats")
+)
+
+// Item - a memcached item
+type Item struct {
+
+	// Key - the item's key
+	Key []byte
+
+	// Value - the item's value
+	Value []byte
+
+	// Flags - the item's opaque 32 bits client flags
+	Flags uint32
+
+	// CAS - the item's cas unique (only filled by the "gets" and "gats" commands)
+	CAS uint64
+}
+
+// CASResult - the result of a compare and swap operation
+type CASResult int
+
+const (
+	// CASStored - the item was stored
+	CASStored CASResult = iota
+
+	// CASExists - the item was modified since it was fetched
+	CASExists
+
+	// CASNotFound - the item does not exist or has expired
+	CASNotFound
 )
 
 // countOperation - send the operation count metric
@@ -68,9 +137,29 @@ func (z *Zencached) countOperation(host string, operation memcachedCommand) {
 	)
 }
 
+// countHitOrMiss - send the cache hit or miss metric
+func (z *Zencached) countHitOrMiss(host string, operation memcachedCommand, hit bool) {
+
+	metric := metricCacheMiss
+	if hit {
+		metric = metricCacheHit
+	}
+
+	z.metricsCollector.Count(
+		1,
+		metric,
+		tagNodeName, host,
+		tagOperationName, string(operation),
+	)
+}
+
 // executeSend - sends a message to memcached
 func (z *Zencached) executeSend(telnetConn *Telnet, operation memcachedCommand, renderedCmd []byte) error {
 
+	if err := z.checkCircuit(telnetConn); err != nil {
+		return err
+	}
+
 	if !z.enableMetrics {
 
 		err := telnetConn.Send(renderedCmd)
@@ -98,59 +187,78 @@ func (z *Zencached) executeSend(telnetConn *Telnet, operation memcachedCommand,
 	return nil
 }
 
-// checkResponse - checks the memcached response
-func (z *Zencached) checkResponse(telnetConn *Telnet, checkReadSet, checkResponseSet [][]byte, operation memcachedCommand) (bool, []byte, error) {
+// isErrorResponse - checks if the line is an ERROR, CLIENT_ERROR or SERVER_ERROR response
+func isErrorResponse(line []byte) bool {
+
+	return bytes.Equal(line, mcrError) || bytes.HasPrefix(line, mcrClientError) || bytes.HasPrefix(line, mcrServerError)
+}
+
+// hasDataBlock - checks if the command sends a data block after the command line
+func hasDataBlock(operation memcachedCommand) bool {
+
+	return isStorageCommand(operation) || bytes.Equal(operation, cas) || bytes.Equal(operation, ms)
+}
+
+// responseError - creates the error of an unexpected response, marking the connection as broken unless the
+// response is a complete error response to a single line command (the server may run the data block of
+// a rejected command line as another command, sending one more response)
+func responseError(telnetConn *Telnet, operation memcachedCommand, response []byte) error {
+
+	if !isErrorResponse(response) || hasDataBlock(operation) {
+		telnetConn.markBroken()
+	}
+
+	return fmt.Errorf("memcached operation error on command %s: %s", operation, response)
+}
+
+// checkResponse - reads a single response line and checks if it is the positive or the negative response
+func (z *Zencached) checkResponse(telnetConn *Telnet, checkResponseSet [][]byte, operation memcachedCommand) (bool, error) {
 
-	response, err := telnetConn.Read(checkReadSet)
+	response, err := telnetConn.ReadLine()
 	if err != nil {
-		return false, nil, err
+		return false, err
 	}
 
-	if !bytes.HasPrefix(response, checkResponseSet[0]) {
-		if !bytes.Contains(response, checkResponseSet[1]) {
-			return false, nil, fmt.Errorf("memcached operation error on command:\n%s", operation)
+	if !bytes.Equal(response, checkResponseSet[0]) {
+		if !bytes.Equal(response, checkResponseSet[1]) {
+			return false, responseError(telnetConn, operation, response)
 		}
 
 		if z.enableMetrics {
-			z.metricsCollector.Count(
-				1,
-				metricCacheMiss,
-				tagNodeName, telnetConn.GetHost(),
-				tagOperationName, string(operation),
-			)
+			z.countHitOrMiss(telnetConn.GetHost(), operation, false)
 		}
 
-		return false, response, nil
+		return false, nil
 	}
 
 	if z.enableMetrics {
-		z.metricsCollector.Count(
-			1,
-			metricCacheHit,
-			tagNodeName, telnetConn.GetHost(),
-			tagOperationName, string(operation),
-		)
+		z.countHitOrMiss(telnetConn.GetHost(), operation, true)
 	}
 
-	return true, response, nil
+	return true, nil
 }
 
-// renderStorageCmd - like Sprintf, but in bytes
-func (z *Zencached) renderStorageCmd(cmd memcachedCommand, key, value, ttl []byte) []byte {
+// renderStorageCmd - like Sprintf, but in bytes (the cas unique is only written if not empty)
+func (z *Zencached) renderStorageCmd(cmd memcachedCommand, key, value, ttl []byte, flags uint32, casUnique []byte) []byte {
 
 	length := strconv.Itoa(len(value))
+	flagsStr := strconv.FormatUint(uint64(flags), 10)
 
 	buffer := bytes.Buffer{}
-	buffer.Grow(len(cmd) + len(key) + len(value) + len(ttl) + len(length) + 4 + (len(doubleBreaks) * 2) + 1)
+	buffer.Grow(len(cmd) + len(key) + len(value) + len(ttl) + len(length) + len(flagsStr) + len(casUnique) + 5 + (len(doubleBreaks) * 2))
 	buffer.Write(cmd)
 	buffer.WriteByte(whiteSpace)
 	buffer.Write(key)
 	buffer.WriteByte(whiteSpace)
-	buffer.WriteByte(zero)
+	buffer.WriteString(flagsStr)
 	buffer.WriteByte(whiteSpace)
 	buffer.Write(ttl)
 	buffer.WriteByte(whiteSpace)
 	buffer.WriteString(length)
+	if len(casUnique) > 0 {
+		buffer.WriteByte(whiteSpace)
+		buffer.Write(casUnique)
+	}
 	buffer.Write(doubleBreaks)
 	buffer.Write(value)
 	buffer.Write(doubleBreaks)
@@ -161,25 +269,75 @@ func (z *Zencached) renderStorageCmd(cmd memcachedCommand, key, value, ttl []byt
 // Storage - performs an storage operation
 func (z *Zencached) Storage(cmd memcachedCommand, routerHash, key, value, ttl []byte) (bool, error) {
 
-	telnetConn, index := z.GetTelnetConnection(routerHash, key)
+	return z.StorageWithFlags(cmd, routerHash, key, value, ttl, 0)
+}
+
+// StorageContext - same as Storage, but using the context to cancel the operation
+func (z *Zencached) StorageContext(ctx context.Context, cmd memcachedCommand, routerHash, key, value, ttl []byte) (bool, error) {
+
+	return z.StorageWithFlagsContext(ctx, cmd, routerHash, key, value, ttl, 0)
+}
+
+// StorageWithFlags - performs an storage operation setting the item's client flags
+func (z *Zencached) StorageWithFlags(cmd memcachedCommand, routerHash, key, value, ttl []byte, flags uint32) (bool, error) {
+
+	return z.StorageWithFlagsContext(context.Background(), cmd, routerHash, key, value, ttl, flags)
+}
+
+// StorageWithFlagsContext - same as StorageWithFlags, but using the context to cancel the operation
+func (z *Zencached) StorageWithFlagsContext(ctx context.Context, cmd memcachedCommand, routerHash, key, value, ttl []byte, flags uint32) (bool, error) {
+
+	telnetConn, index, err := z.GetTelnetConnectionContext(ctx, routerHash, key)
+	if err != nil {
+		return false, err
+	}
 	defer z.ReturnTelnetConnection(telnetConn, index)
 
-	return z.baseStorage(telnetConn, cmd, key, value, ttl)
+	return z.baseStorage(telnetConn, cmd, key, value, ttl, flags)
 }
 
-// baseStorage - base storage function
-func (z *Zencached) baseStorage(telnetConn *Telnet, cmd memcachedCommand, key, value, ttl []byte) (bool, error) {
+// isStorageCommand - checks if the command is a storage command
+func isStorageCommand(cmd memcachedCommand) bool {
+
+	for i := 0; i < len(storageCommands); i++ {
+		if bytes.Equal(cmd, storageCommands[i]) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// baseStorage - base storage function (a missing key for replace, append and prepend returns false)
+func (z *Zencached) baseStorage(telnetConn *Telnet, cmd memcachedCommand, key, value, ttl []byte, flags uint32) (bool, error) {
+
+	if !isStorageCommand(cmd) {
+		return false, fmt.Errorf("invalid storage command: %s", cmd)
+	}
 
 	if z.enableMetrics {
 		z.countOperation(telnetConn.GetHost(), cmd)
 	}
 
-	err := z.executeSend(telnetConn, cmd, z.renderStorageCmd(cmd, key, value, ttl))
+	if z.isBinaryProtocol() {
+		status, err := z.baseBinaryStorage(telnetConn, cmd, key, value, ttl, flags, 0)
+		if err != nil {
+			return false, err
+		}
+
+		if z.enableMetrics {
+			z.countHitOrMiss(telnetConn.GetHost(), cmd, status == binaryStatusNoError)
+		}
+
+		return status == binaryStatusNoError, nil
+	}
+
+	err := z.executeSend(telnetConn, cmd, z.renderStorageCmd(cmd, key, value, ttl, flags, nil))
 	if err != nil {
 		return false, err
 	}
 
-	wasStored, _, err := z.checkResponse(telnetConn, mcrStoredResponseSet, mcrStoredResponseSet, cmd)
+	wasStored, err := z.checkResponse(telnetConn, mcrStoredResponseSet, cmd)
 	if err != nil {
 		return false, err
 	}
@@ -203,7 +361,16 @@ func (z *Zencached) renderKeyOnlyCmd(cmd memcachedCommand, key []byte) []byte {
 // Get - performs a get operation
 func (z *Zencached) Get(routerHash []byte, key []byte) ([]byte, bool, error) {
 
-	telnetConn, index := z.GetTelnetConnection(routerHash, key)
+	return z.GetContext(context.Background(), routerHash, key)
+}
+
+// GetContext - same as Get, but using the context to cancel the operation
+func (z *Zencached) GetContext(ctx context.Context, routerHash []byte, key []byte) ([]byte, bool, error) {
+
+	telnetConn, index, err := z.GetTelnetConnectionContext(ctx, routerHash, key)
+	if err != nil {
+		return nil, false, err
+	}
 	defer z.ReturnTelnetConnection(telnetConn, index)
 
 	return z.baseGet(telnetConn, key)
@@ -212,58 +379,448 @@ func (z *Zencached) Get(routerHash []byte, key []byte) ([]byte, bool, error) {
 // baseGet - the base get operation
 func (z *Zencached) baseGet(telnetConn *Telnet, key []byte) ([]byte, bool, error) {
 
+	item, found, err := z.baseGetItem(telnetConn, get, key)
+	if !found || err != nil {
+		return nil, false, err
+	}
+
+	return item.Value, true, nil
+}
+
+// renderMultiKeyCmd - like Sprintf, but in bytes
+func (z *Zencached) renderMultiKeyCmd(cmd memcachedCommand, keys [][]byte) []byte {
+
+	size := len(cmd) + len(doubleBreaks)
+	for i := 0; i < len(keys); i++ {
+		size += len(keys[i]) + 1
+	}
+
+	buffer := bytes.Buffer{}
+	buffer.Grow(size)
+	buffer.Write(cmd)
+
+	for i := 0; i < len(keys); i++ {
+		buffer.WriteByte(whiteSpace)
+		buffer.Write(keys[i])
+	}
+
+	buffer.Write(doubleBreaks)
+
+	return buffer.Bytes()
+}
+
+// multiGetResult - the result of a multi key get on a single node
+type multiGetResult struct {
+	values    map[string][]byte
+	err       error
+	positions []int
+}
+
+// GetMulti - performs a get operation with many keys, sending a single command to each node concurrently
+// (routerHashes may be nil, if not it must have the same length of keys) and returns only the found keys
+func (z *Zencached) GetMulti(routerHashes [][]byte, keys [][]byte) (map[string][]byte, error) {
+
+	return z.GetMultiContext(context.Background(), routerHashes, keys)
+}
+
+// GetMultiContext - same as GetMulti, but using the context to cancel the operation
+// (the keys of a node removed meanwhile are routed again using the new node set)
+func (z *Zencached) GetMultiContext(ctx context.Context, routerHashes [][]byte, keys [][]byte) (map[string][]byte, error) {
+
+	positions := make([]int, len(keys))
+	for i := 0; i < len(keys); i++ {
+		positions[i] = i
+	}
+
+	hits := make(map[string][]byte, len(keys))
+	var err error
+
+	for len(positions) > 0 {
+		positions, err = z.getMultiFromNodes(ctx, routerHashes, keys, positions, hits, err)
+	}
+
+	return hits, err
+}
+
+// getMultiFromNodes - gets the keys at the positions from their nodes, storing the hits and returning the positions
+// of the keys owned by the removed nodes along with the first error
+func (z *Zencached) getMultiFromNodes(ctx context.Context, routerHashes, keys [][]byte, positions []int, hits map[string][]byte, firstErr error) ([]int, error) {
+
+	nodes := z.loadNodes()
+	nodePositions := map[int][]int{}
+
+	for _, position := range positions {
+
+		var routerHash []byte
+		if routerHashes != nil {
+			routerHash = routerHashes[position]
+		}
+
+		index := z.routeIndex(nodes, routerHash, keys[position])
+		nodePositions[index] = append(nodePositions[index], position)
+	}
+
+	results := make(chan multiGetResult, len(nodePositions))
+
+	for index, positions := range nodePositions {
+
+		go func(index int, positions []int) {
+
+			telnetConn, err := z.acquireTelnetConn(ctx, nodes.pools[index], z.configuration.ConnectionAcquireTimeout)
+			if err != nil {
+				results <- multiGetResult{
+					err:       err,
+					positions: positions,
+				}
+				return
+			}
+			defer z.ReturnTelnetConnection(telnetConn, index)
+
+			nodeKeys := make([][]byte, len(positions))
+			for i := 0; i < len(positions); i++ {
+				nodeKeys[i] = keys[positions[i]]
+			}
+
+			values, err := z.baseGetMulti(telnetConn, nodeKeys)
+
+			results <- multiGetResult{
+				values: values,
+				err:    err,
+			}
+		}(index, positions)
+	}
+
+	var removed []int
+
+	for i := 0; i < len(nodePositions); i++ {
+
+		result := <-results
+		if result.err == ErrNodeRemoved {
+			removed = append(removed, result.positions...)
+			continue
+		}
+
+		if result.err != nil {
+			if firstErr == nil {
+				firstErr = result.err
+			}
+			continue
+		}
+
+		for key, value := range result.values {
+			hits[key] = value
+		}
+	}
+
+	return removed, firstErr
+}
+
+// baseGetMulti - the base multi key get operation
+func (z *Zencached) baseGetMulti(telnetConn *Telnet, keys [][]byte) (map[string][]byte, error) {
+
 	if z.enableMetrics {
 		z.countOperation(telnetConn.GetHost(), get)
 	}
 
-	err := z.executeSend(telnetConn, get, z.renderKeyOnlyCmd(get, key))
+	var items []*Item
+	var err error
+
+	if z.isBinaryProtocol() {
+		items, err = z.baseBinaryGetMulti(telnetConn, keys)
+	} else {
+		items, err = z.baseTextGetMulti(telnetConn, keys)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[string][]byte, len(items))
+	for i := 0; i < len(items); i++ {
+		values[string(items[i].Key)] = items[i].Value
+	}
+
+	if z.enableMetrics {
+		for i := 0; i < len(keys); i++ {
+			_, found := values[string(keys[i])]
+			z.countHitOrMiss(telnetConn.GetHost(), get, found)
+		}
+	}
+
+	return values, nil
+}
+
+// baseTextGetMulti - sends a single get command with all keys
+func (z *Zencached) baseTextGetMulti(telnetConn *Telnet, keys [][]byte) ([]*Item, error) {
+
+	err := z.executeSend(telnetConn, get, z.renderMultiKeyCmd(get, keys))
+	if err != nil {
+		return nil, err
+	}
+
+	return z.readValues(telnetConn)
+}
+
+// readValues - reads all VALUE blocks until the END line, using the declared length to read each value
+func (z *Zencached) readValues(telnetConn *Telnet) ([]*Item, error) {
+
+	var items []*Item
+
+	for {
+		line, err := telnetConn.ReadLine()
+		if err != nil {
+			return nil, err
+		}
+
+		if bytes.Equal(line, mcrEnd) {
+			return items, nil
+		}
+
+		if isErrorResponse(line) {
+			return nil, fmt.Errorf("memcached operation error: %s", line)
+		}
+
+		item, length, err := z.parseValueHeader(line)
+		if err != nil {
+			telnetConn.markBroken()
+			return nil, err
+		}
+
+		data, err := telnetConn.ReadFull(length + len(doubleBreaks))
+		if err != nil {
+			return nil, err
+		}
+
+		if !bytes.HasSuffix(data, doubleBreaks) {
+			telnetConn.markBroken()
+			return nil, fmt.Errorf("value length mismatch: %s", line)
+		}
+
+		item.Value = data[:length]
+		items = append(items, item)
+	}
+}
+
+// parseValueHeader - parses the "VALUE <key> <flags> <bytes> [<cas unique>]" line
+func (z *Zencached) parseValueHeader(line []byte) (*Item, int, error) {
+
+	fields := bytes.Fields(line)
+	if len(fields) < 4 || !bytes.Equal(fields[0], mcrValue) {
+		return nil, 0, fmt.Errorf("unexpected get response: %s", line)
+	}
+
+	flags, err := strconv.ParseUint(string(fields[2]), 10, 32)
+	if err != nil {
+		return nil, 0, fmt.Errorf("invalid flags: %s", line)
+	}
+
+	length, err := strconv.Atoi(string(fields[3]))
+	if err != nil || length < 0 {
+		return nil, 0, fmt.Errorf("invalid value length: %s", line)
+	}
+
+	item := &Item{
+		Key:   fields[1],
+		Flags: uint32(flags),
+	}
+
+	if len(fields) > 4 {
+		item.CAS, err = strconv.ParseUint(string(fields[4]), 10, 64)
+		if err != nil {
+			return nil, 0, fmt.Errorf("invalid cas unique: %s", line)
+		}
+	}
+
+	return item, length, nil
+}
+
+// GetItem - performs a get operation returning the item with its client flags
+func (z *Zencached) GetItem(routerHash []byte, key []byte) (*Item, bool, error) {
+
+	return z.GetItemContext(context.Background(), routerHash, key)
+}
+
+// GetItemContext - same as GetItem, but using the context to cancel the operation
+func (z *Zencached) GetItemContext(ctx context.Context, routerHash []byte, key []byte) (*Item, bool, error) {
+
+	telnetConn, index, err := z.GetTelnetConnectionContext(ctx, routerHash, key)
 	if err != nil {
 		return nil, false, err
 	}
+	defer z.ReturnTelnetConnection(telnetConn, index)
+
+	return z.baseGetItem(telnetConn, get, key)
+}
+
+// Gets - performs a get operation returning the item with its client flags and cas unique
+func (z *Zencached) Gets(routerHash []byte, key []byte) (*Item, bool, error) {
+
+	return z.GetsContext(context.Background(), routerHash, key)
+}
 
-	exists, response, err := z.checkResponse(telnetConn, mcrGetCheckResponseSet, mcrGetCheckEndResponseSet, get)
-	if !exists || err != nil {
+// GetsContext - same as Gets, but using the context to cancel the operation
+func (z *Zencached) GetsContext(ctx context.Context, routerHash []byte, key []byte) (*Item, bool, error) {
+
+	telnetConn, index, err := z.GetTelnetConnectionContext(ctx, routerHash, key)
+	if err != nil {
 		return nil, false, err
 	}
+	defer z.ReturnTelnetConnection(telnetConn, index)
+
+	return z.baseGetItem(telnetConn, gets, key)
+}
+
+// baseGetItem - the base get operation returning the full item (get and gets commands)
+func (z *Zencached) baseGetItem(telnetConn *Telnet, cmd memcachedCommand, key []byte) (*Item, bool, error) {
+
+	if z.enableMetrics {
+		z.countOperation(telnetConn.GetHost(), cmd)
+	}
+
+	var item *Item
+	var err error
+
+	if z.isBinaryProtocol() {
+		item, err = z.baseBinaryGet(telnetConn, cmd, key)
+	} else {
+		item, err = z.baseTextGetItem(telnetConn, cmd, key)
+	}
 
-	start, end, err := z.extractValue([]byte(response))
 	if err != nil {
 		return nil, false, err
 	}
 
-	return response[start:end], true, nil
+	if z.enableMetrics {
+		z.countHitOrMiss(telnetConn.GetHost(), cmd, item != nil)
+	}
+
+	return item, item != nil, nil
 }
 
-// extractValue - extracts a value from the response
-func (z *Zencached) extractValue(response []byte) (start, end int, err error) {
+// baseTextGetItem - sends a get or gets command with a single key
+func (z *Zencached) baseTextGetItem(telnetConn *Telnet, cmd memcachedCommand, key []byte) (*Item, error) {
 
-	start = -1
-	end = -1
+	err := z.executeSend(telnetConn, cmd, z.renderKeyOnlyCmd(cmd, key))
+	if err != nil {
+		return nil, err
+	}
 
-	for i := 0; i < len(response); i++ {
-		if start == -1 && response[i] == lineBreaksN {
-			start = i + 1
-		} else if start >= 0 && response[i] == lineBreaksR {
-			end = i
-			break
-		}
+	items, err := z.readValues(telnetConn)
+	if err != nil || len(items) == 0 {
+		return nil, err
 	}
 
-	if start == -1 {
-		err = fmt.Errorf("no value found")
+	if !bytes.Equal(items[0].Key, key) {
+		telnetConn.markBroken()
+		return nil, fmt.Errorf("memcached operation error on command %s: unexpected key %q", cmd, items[0].Key)
 	}
 
-	if end == -1 {
-		end = len(response) - 1
+	return items[0], nil
+}
+
+// CompareAndSwap - stores the item only if its cas unique still matches the stored one
+func (z *Zencached) CompareAndSwap(routerHash []byte, item *Item, ttl []byte) (CASResult, error) {
+
+	return z.CompareAndSwapContext(context.Background(), routerHash, item, ttl)
+}
+
+// CompareAndSwapContext - same as CompareAndSwap, but using the context to cancel the operation
+func (z *Zencached) CompareAndSwapContext(ctx context.Context, routerHash []byte, item *Item, ttl []byte) (CASResult, error) {
+
+	telnetConn, index, err := z.GetTelnetConnectionContext(ctx, routerHash, item.Key)
+	if err != nil {
+		return CASNotFound, err
 	}
+	defer z.ReturnTelnetConnection(telnetConn, index)
 
-	return
+	return z.baseCompareAndSwap(telnetConn, item, ttl)
+}
+
+// baseCompareAndSwap - the base compare and swap operation
+func (z *Zencached) baseCompareAndSwap(telnetConn *Telnet, item *Item, ttl []byte) (CASResult, error) {
+
+	if z.enableMetrics {
+		z.countOperation(telnetConn.GetHost(), cas)
+	}
+
+	var result CASResult
+	var err error
+
+	if z.isBinaryProtocol() {
+		result, err = z.baseBinaryCompareAndSwap(telnetConn, item, ttl)
+	} else {
+		result, err = z.baseTextCompareAndSwap(telnetConn, item, ttl)
+	}
+
+	if err != nil {
+		return CASNotFound, err
+	}
+
+	if z.enableMetrics {
+		z.countHitOrMiss(telnetConn.GetHost(), cas, result == CASStored)
+	}
+
+	return result, nil
+}
+
+// baseTextCompareAndSwap - sends the cas command
+func (z *Zencached) baseTextCompareAndSwap(telnetConn *Telnet, item *Item, ttl []byte) (CASResult, error) {
+
+	casUnique := []byte(strconv.FormatUint(item.CAS, 10))
+
+	err := z.executeSend(telnetConn, cas, z.renderStorageCmd(cas, item.Key, item.Value, ttl, item.Flags, casUnique))
+	if err != nil {
+		return CASNotFound, err
+	}
+
+	response, err := telnetConn.ReadLine()
+	if err != nil {
+		return CASNotFound, err
+	}
+
+	switch {
+	case bytes.Equal(response, mcrStored):
+		return CASStored, nil
+	case bytes.Equal(response, mcrExists):
+		return CASExists, nil
+	case bytes.Equal(response, mcrNotFound):
+		return CASNotFound, nil
+	default:
+		return CASNotFound, responseError(telnetConn, cas, response)
+	}
+}
+
+// baseBinaryCompareAndSwap - sends a set request with the cas unique
+func (z *Zencached) baseBinaryCompareAndSwap(telnetConn *Telnet, item *Item, ttl []byte) (CASResult, error) {
+
+	status, err := z.baseBinaryStorage(telnetConn, Set, item.Key, item.Value, ttl, item.Flags, item.CAS)
+	if err != nil {
+		return CASNotFound, err
+	}
+
+	switch status {
+	case binaryStatusNoError:
+		return CASStored, nil
+	case binaryStatusKeyExists:
+		return CASExists, nil
+	default:
+		return CASNotFound, nil
+	}
 }
 
 // Delete - performs a delete operation
 func (z *Zencached) Delete(routerHash []byte, key []byte) (bool, error) {
 
-	telnetConn, index := z.GetTelnetConnection(routerHash, key)
+	return z.DeleteContext(context.Background(), routerHash, key)
+}
+
+// DeleteContext - same as Delete, but using the context to cancel the operation
+func (z *Zencached) DeleteContext(ctx context.Context, routerHash []byte, key []byte) (bool, error) {
+
+	telnetConn, index, err := z.GetTelnetConnectionContext(ctx, routerHash, key)
+	if err != nil {
+		return false, err
+	}
 	defer z.ReturnTelnetConnection(telnetConn, index)
 
 	return z.baseDelete(telnetConn, key)
@@ -276,15 +833,302 @@ func (z *Zencached) baseDelete(telnetConn *Telnet, key []byte) (bool, error) {
 		z.countOperation(telnetConn.GetHost(), delete)
 	}
 
+	if z.isBinaryProtocol() {
+		exists, err := z.baseBinaryDelete(telnetConn, key)
+		if err != nil {
+			return false, err
+		}
+
+		if z.enableMetrics {
+			z.countHitOrMiss(telnetConn.GetHost(), delete, exists)
+		}
+
+		return exists, nil
+	}
+
 	err := z.executeSend(telnetConn, delete, z.renderKeyOnlyCmd(delete, key))
 	if err != nil {
 		return false, err
 	}
 
-	exists, _, err := z.checkResponse(telnetConn, mcrDeletedResponseSet, mcrDeletedResponseSet, delete)
+	exists, err := z.checkResponse(telnetConn, mcrDeletedResponseSet, delete)
 	if err != nil {
 		return false, err
 	}
 
 	return exists, nil
 }
+
+// renderKeyArgumentCmd - like Sprintf, but in bytes
+func (z *Zencached) renderKeyArgumentCmd(cmd memcachedCommand, key, argument []byte) []byte {
+
+	buffer := bytes.Buffer{}
+	buffer.Grow(len(cmd) + len(key) + len(argument) + 2 + len(doubleBreaks))
+	buffer.Write(cmd)
+	buffer.WriteByte(whiteSpace)
+	buffer.Write(key)
+	buffer.WriteByte(whiteSpace)
+	buffer.Write(argument)
+	buffer.Write(doubleBreaks)
+
+	return buffer.Bytes()
+}
+
+// Increment - increments the numeric value of a key, returning the new value and if the key was found
+func (z *Zencached) Increment(routerHash, key []byte, delta uint64) (uint64, bool, error) {
+
+	return z.IncrementContext(context.Background(), routerHash, key, delta)
+}
+
+// IncrementContext - same as Increment, but using the context to cancel the operation
+func (z *Zencached) IncrementContext(ctx context.Context, routerHash, key []byte, delta uint64) (uint64, bool, error) {
+
+	telnetConn, index, err := z.GetTelnetConnectionContext(ctx, routerHash, key)
+	if err != nil {
+		return 0, false, err
+	}
+	defer z.ReturnTelnetConnection(telnetConn, index)
+
+	return z.baseArithmetic(telnetConn, incr, key, delta)
+}
+
+// Decrement - decrements the numeric value of a key, returning the new value and if the key was found
+func (z *Zencached) Decrement(routerHash, key []byte, delta uint64) (uint64, bool, error) {
+
+	return z.DecrementContext(context.Background(), routerHash, key, delta)
+}
+
+// DecrementContext - same as Decrement, but using the context to cancel the operation
+func (z *Zencached) DecrementContext(ctx context.Context, routerHash, key []byte, delta uint64) (uint64, bool, error) {
+
+	telnetConn, index, err := z.GetTelnetConnectionContext(ctx, routerHash, key)
+	if err != nil {
+		return 0, false, err
+	}
+	defer z.ReturnTelnetConnection(telnetConn, index)
+
+	return z.baseArithmetic(telnetConn, decr, key, delta)
+}
+
+// IncrementWithSeed - increments the numeric value of a key, adding the seed value if the key was not found
+func (z *Zencached) IncrementWithSeed(routerHash, key []byte, delta, seed uint64, ttl []byte) (uint64, error) {
+
+	return z.IncrementWithSeedContext(context.Background(), routerHash, key, delta, seed, ttl)
+}
+
+// IncrementWithSeedContext - same as IncrementWithSeed, but using the context to cancel the operation
+func (z *Zencached) IncrementWithSeedContext(ctx context.Context, routerHash, key []byte, delta, seed uint64, ttl []byte) (uint64, error) {
+
+	telnetConn, index, err := z.GetTelnetConnectionContext(ctx, routerHash, key)
+	if err != nil {
+		return 0, err
+	}
+	defer z.ReturnTelnetConnection(telnetConn, index)
+
+	return z.baseArithmeticWithSeed(telnetConn, incr, key, delta, seed, ttl)
+}
+
+// DecrementWithSeed - decrements the numeric value of a key, adding the seed value if the key was not found
+func (z *Zencached) DecrementWithSeed(routerHash, key []byte, delta, seed uint64, ttl []byte) (uint64, error) {
+
+	return z.DecrementWithSeedContext(context.Background(), routerHash, key, delta, seed, ttl)
+}
+
+// DecrementWithSeedContext - same as DecrementWithSeed, but using the context to cancel the operation
+func (z *Zencached) DecrementWithSeedContext(ctx context.Context, routerHash, key []byte, delta, seed uint64, ttl []byte) (uint64, error) {
+
+	telnetConn, index, err := z.GetTelnetConnectionContext(ctx, routerHash, key)
+	if err != nil {
+		return 0, err
+	}
+	defer z.ReturnTelnetConnection(telnetConn, index)
+
+	return z.baseArithmeticWithSeed(telnetConn, decr, key, delta, seed, ttl)
+}
+
+// baseArithmeticWithSeed - the base arithmetic operation falling back to the add command
+func (z *Zencached) baseArithmeticWithSeed(telnetConn *Telnet, cmd memcachedCommand, key []byte, delta, seed uint64, ttl []byte) (uint64, error) {
+
+	value, found, err := z.baseArithmetic(telnetConn, cmd, key, delta)
+	if err != nil || found {
+		return value, err
+	}
+
+	stored, err := z.baseStorage(telnetConn, Add, key, []byte(strconv.FormatUint(seed, 10)), ttl, 0)
+	if err != nil {
+		return 0, err
+	}
+
+	if stored {
+		return seed, nil
+	}
+
+	// another client added the key first
+	value, found, err = z.baseArithmetic(telnetConn, cmd, key, delta)
+	if err != nil {
+		return 0, err
+	}
+
+	if !found {
+		return 0, fmt.Errorf("key was removed while seeding the %s operation", cmd)
+	}
+
+	return value, nil
+}
+
+// baseArithmetic - the base arithmetic operation
+func (z *Zencached) baseArithmetic(telnetConn *Telnet, cmd memcachedCommand, key []byte, delta uint64) (uint64, bool, error) {
+
+	if err := z.checkTextProtocol(cmd); err != nil {
+		return 0, false, err
+	}
+
+	if z.enableMetrics {
+		z.countOperation(telnetConn.GetHost(), cmd)
+	}
+
+	err := z.executeSend(telnetConn, cmd, z.renderKeyArgumentCmd(cmd, key, []byte(strconv.FormatUint(delta, 10))))
+	if err != nil {
+		return 0, false, err
+	}
+
+	response, err := telnetConn.ReadLine()
+	if err != nil {
+		return 0, false, err
+	}
+
+	if bytes.Equal(response, mcrNotFound) {
+		if z.enableMetrics {
+			z.countHitOrMiss(telnetConn.GetHost(), cmd, false)
+		}
+
+		return 0, false, nil
+	}
+
+	value, err := strconv.ParseUint(string(response), 10, 64)
+	if err != nil {
+		return 0, false, responseError(telnetConn, cmd, response)
+	}
+
+	if z.enableMetrics {
+		z.countHitOrMiss(telnetConn.GetHost(), cmd, true)
+	}
+
+	return value, true, nil
+}
+
+// Touch - updates the expiration time of a key without fetching it
+func (z *Zencached) Touch(routerHash, key, ttl []byte) (bool, error) {
+
+	return z.TouchContext(context.Background(), routerHash, key, ttl)
+}
+
+// TouchContext - same as Touch, but using the context to cancel the operation
+func (z *Zencached) TouchContext(ctx context.Context, routerHash, key, ttl []byte) (bool, error) {
+
+	telnetConn, index, err := z.GetTelnetConnectionContext(ctx, routerHash, key)
+	if err != nil {
+		return false, err
+	}
+	defer z.ReturnTelnetConnection(telnetConn, index)
+
+	return z.baseTouch(telnetConn, key, ttl)
+}
+
+// baseTouch - the base touch operation
+func (z *Zencached) baseTouch(telnetConn *Telnet, key, ttl []byte) (bool, error) {
+
+	if err := z.checkTextProtocol(touch); err != nil {
+		return false, err
+	}
+
+	if z.enableMetrics {
+		z.countOperation(telnetConn.GetHost(), touch)
+	}
+
+	err := z.executeSend(telnetConn, touch, z.renderKeyArgumentCmd(touch, key, ttl))
+	if err != nil {
+		return false, err
+	}
+
+	touched, err := z.checkResponse(telnetConn, mcrTouchedResponseSet, touch)
+	if err != nil {
+		return false, err
+	}
+
+	return touched, nil
+}
+
+// GetAndTouch - performs a get operation updating the expiration time of the key
+func (z *Zencached) GetAndTouch(routerHash, key, ttl []byte) ([]byte, bool, error) {
+
+	return z.GetAndTouchContext(context.Background(), routerHash, key, ttl)
+}
+
+// GetAndTouchContext - same as GetAndTouch, but using the context to cancel the operation
+func (z *Zencached) GetAndTouchContext(ctx context.Context, routerHash, key, ttl []byte) ([]byte, bool, error) {
+
+	telnetConn, index, err := z.GetTelnetConnectionContext(ctx, routerHash, key)
+	if err != nil {
+		return nil, false, err
+	}
+	defer z.ReturnTelnetConnection(telnetConn, index)
+
+	item, found, err := z.baseGetAndTouch(telnetConn, gat, key, ttl)
+	if !found || err != nil {
+		return nil, false, err
+	}
+
+	return item.Value, true, nil
+}
+
+// GetsAndTouch - performs a gets operation updating the expiration time of the key
+func (z *Zencached) GetsAndTouch(routerHash, key, ttl []byte) (*Item, bool, error) {
+
+	return z.GetsAndTouchContext(context.Background(), routerHash, key, ttl)
+}
+
+// GetsAndTouchContext - same as GetsAndTouch, but using the context to cancel the operation
+func (z *Zencached) GetsAndTouchContext(ctx context.Context, routerHash, key, ttl []byte) (*Item, bool, error) {
+
+	telnetConn, index, err := z.GetTelnetConnectionContext(ctx, routerHash, key)
+	if err != nil {
+		return nil, false, err
+	}
+	defer z.ReturnTelnetConnection(telnetConn, index)
+
+	return z.baseGetAndTouch(telnetConn, gats, key, ttl)
+}
+
+// baseGetAndTouch - the base get and touch operation (gat and gats commands)
+func (z *Zencached) baseGetAndTouch(telnetConn *Telnet, cmd memcachedCommand, key, ttl []byte) (*Item, bool, error) {
+
+	if err := z.checkTextProtocol(cmd); err != nil {
+		return nil, false, err
+	}
+
+	if z.enableMetrics {
+		z.countOperation(telnetConn.GetHost(), cmd)
+	}
+
+	// the expiration time comes before the key on these commands
+	err := z.executeSend(telnetConn, cmd, z.renderKeyArgumentCmd(cmd, ttl, key))
+	if err != nil {
+		return nil, false, err
+	}
+
+	items, err := z.readValues(telnetConn)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if z.enableMetrics {
+		z.countHitOrMiss(telnetConn.GetHost(), cmd, len(items) > 0)
+	}
+
+	if len(items) == 0 {
+		return nil, false, nil
+	}
+
+	return items[0], true, nil
+}
@@ -0,0 +1,75 @@
+package zencached_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/uol/zencached"
+)
+
+// withAcquireTimeout - sets the connection acquisition timeout
+func withAcquireTimeout(timeout time.Duration) configOption {
+
+	return func(c *zencached.Configuration) {
+		c.ConnectionAcquireTimeout = timeout
+	}
+}
+
+// TestPoolExhausted - tests if the acquisition timeout returns the pool exhausted error
+func TestPoolExhausted(t *testing.T) {
+
+	tc := &testCollector{
+		collected: []string{},
+	}
+
+	z := createLocalZencached(createStaticNodes(1), tc, withAcquireTimeout(100*time.Millisecond))
+	telnetConn := z.GetTelnetConnByNodeIndex(0)
+	tc.collected = []string{}
+
+	start := time.Now()
+	_, err := z.Delete(nil, []byte("key"))
+	elapsedTime := time.Since(start)
+
+	assert.Equal(t, zencached.ErrPoolExhausted, err, "expected the pool exhausted error")
+	assert.True(t, elapsedTime >= 100*time.Millisecond && elapsedTime < time.Second, "expected to wait the acquisition timeout")
+
+	var timeouts, waits int
+	for i := 0; i < len(tc.collected); i++ {
+		if strings.HasPrefix(tc.collected[i], "count/zencached.node.conn.timeout/") {
+			timeouts++
+		}
+		if strings.HasPrefix(tc.collected[i], "max/zencached.node.conn.available.time/") {
+			waits++
+		}
+	}
+
+	assert.Equal(t, 1, timeouts, "expected the timeout metric: %v", tc.collected)
+	assert.Equal(t, 1, waits, "expected the wait time metric: %v", tc.collected)
+
+	_, err = z.GetTelnetConnByNodeIndexContext(context.Background(), 0)
+	assert.Equal(t, zencached.ErrPoolExhausted, err, "expected the pool exhausted error by node index")
+
+	z.ReturnTelnetConnection(telnetConn, 0)
+
+	telnetConn, err = z.GetTelnetConnByNodeIndexContext(context.Background(), 0)
+	if assert.NoError(t, err, "expected an idle connection") {
+		z.ReturnTelnetConnection(telnetConn, 0)
+	}
+}
+
+// TestPoolContextBeforeTimeout - tests if the context deadline is respected when shorter than the acquisition timeout
+func TestPoolContextBeforeTimeout(t *testing.T) {
+
+	z := createLocalZencached(createStaticNodes(1), nil, withAcquireTimeout(time.Minute))
+	telnetConn := z.GetTelnetConnByNodeIndex(0)
+	defer z.ReturnTelnetConnection(telnetConn, 0)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	_, _, err := z.GetContext(ctx, nil, []byte("key"))
+	assert.Equal(t, context.DeadlineExceeded, err, "expected the context error")
+}
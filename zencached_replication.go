@@ -0,0 +1,265 @@
+package zencached
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+//
+// Replicated operations, storing each key on a number of nodes chosen by the router
+// and answering according to the write and read quorums.
+//
+
+// QuorumError - returned when not enough replicas have answered the operation
+type QuorumError struct {
+
+	// Required - the quorum required
+	Required int
+
+	// Reached - the number of replicas answering the operation
+	Reached int
+
+	// Errors - the errors of the failed replicas
+	Errors []error
+}
+
+// Error - returns the error message with all replica errors
+func (e *QuorumError) Error() string {
+
+	errors := make([]string, len(e.Errors))
+	for i := 0; i < len(e.Errors); i++ {
+		errors[i] = e.Errors[i].Error()
+	}
+
+	return fmt.Sprintf("quorum not reached, %d of %d replicas answered: %s", e.Reached, e.Required, strings.Join(errors, ", "))
+}
+
+// replicaResult - the result of an operation on a replica
+type replicaResult struct {
+	value []byte
+	ok    bool
+	err   error
+}
+
+// replicationFactor - returns the configured replication factor (default one)
+func (c *Configuration) replicationFactor() int {
+
+	if c.ReplicationFactor <= 0 {
+		return 1
+	}
+
+	return c.ReplicationFactor
+}
+
+// writeQuorum - returns the configured write quorum (default the majority of the replicas)
+func (c *Configuration) writeQuorum() int {
+
+	if c.WriteQuorum <= 0 {
+		return c.replicationFactor()/2 + 1
+	}
+
+	return c.WriteQuorum
+}
+
+// readQuorum - returns the configured read quorum (default one)
+func (c *Configuration) readQuorum() int {
+
+	if c.ReadQuorum <= 0 {
+		return 1
+	}
+
+	return c.ReadQuorum
+}
+
+// checkReplication - returns an error if the quorums are greater than the replication factor or it is greater than the number of nodes
+func (c *Configuration) checkReplication(numNodes int) error {
+
+	if c.WriteQuorum > c.replicationFactor() || c.ReadQuorum > c.replicationFactor() {
+		return fmt.Errorf("the quorums must not be greater than the replication factor: %d", c.replicationFactor())
+	}
+
+	if c.ReplicationFactor > numNodes {
+		return fmt.Errorf("the replication factor %d must not be greater than the number of nodes: %d", c.ReplicationFactor, numNodes)
+	}
+
+	return nil
+}
+
+// sendToReplicas - runs the operation on the key replicas concurrently, releasing each connection as soon as its replica finishes
+// (returns the channel receiving the results and the number of replicas, there are fewer replicas if there are fewer nodes)
+func (z *Zencached) sendToReplicas(ctx context.Context, routerHash, key []byte, operation func(telnetConn *Telnet) replicaResult) (chan replicaResult, int, error) {
+
+	if routerHash == nil {
+		routerHash = key
+	}
+
+	if len(routerHash) == 0 {
+		return nil, 0, fmt.Errorf("the replicated operations require a key")
+	}
+
+	nodes := z.loadNodes()
+	indexes := nodes.replicas(routerHash, z.configuration.replicationFactor())
+
+	results := make(chan replicaResult, len(indexes))
+
+	for _, index := range indexes {
+
+		go func(index int) {
+
+			telnetConn, err := z.acquireTelnetConn(ctx, nodes.pools[index], z.configuration.ConnectionAcquireTimeout)
+			if err != nil {
+				results <- replicaResult{err: err}
+				return
+			}
+
+			result := operation(telnetConn)
+			z.ReturnTelnetConnection(telnetConn, index)
+
+			results <- result
+		}(index)
+	}
+
+	return results, len(indexes), nil
+}
+
+// writeReplicas - waits all replicas, returns the number of replicas answering ok or an error if the write quorum is not reached
+func (z *Zencached) writeReplicas(ctx context.Context, routerHash, key []byte, operation func(telnetConn *Telnet) replicaResult) (int, error) {
+
+	results, numReplicas, err := z.sendToReplicas(ctx, routerHash, key, operation)
+	if err != nil {
+		return 0, err
+	}
+
+	answered, ok := 0, 0
+	var errors []error
+
+	for i := 0; i < numReplicas; i++ {
+
+		result := <-results
+		if result.err != nil {
+			errors = append(errors, result.err)
+			continue
+		}
+
+		answered++
+		if result.ok {
+			ok++
+		}
+	}
+
+	quorum := z.configuration.writeQuorum()
+	if answered < quorum {
+		return ok, &QuorumError{
+			Required: quorum,
+			Reached:  answered,
+			Errors:   errors,
+		}
+	}
+
+	return ok, nil
+}
+
+// ReplicatedStorage - performs a storage operation on the key replicas, returns true if the value was stored on
+// the write quorum and an error if the write quorum has not answered
+func (z *Zencached) ReplicatedStorage(cmd memcachedCommand, routerHash, key, value, ttl []byte) (bool, error) {
+
+	return z.ReplicatedStorageWithFlags(cmd, routerHash, key, value, ttl, 0)
+}
+
+// ReplicatedStorageContext - same as ReplicatedStorage, but using the context to cancel the operation
+func (z *Zencached) ReplicatedStorageContext(ctx context.Context, cmd memcachedCommand, routerHash, key, value, ttl []byte) (bool, error) {
+
+	return z.ReplicatedStorageWithFlagsContext(ctx, cmd, routerHash, key, value, ttl, 0)
+}
+
+// ReplicatedStorageWithFlags - performs a storage operation on the key replicas setting the item's client flags
+func (z *Zencached) ReplicatedStorageWithFlags(cmd memcachedCommand, routerHash, key, value, ttl []byte, flags uint32) (bool, error) {
+
+	return z.ReplicatedStorageWithFlagsContext(context.Background(), cmd, routerHash, key, value, ttl, flags)
+}
+
+// ReplicatedStorageWithFlagsContext - same as ReplicatedStorageWithFlags, but using the context to cancel the operation
+func (z *Zencached) ReplicatedStorageWithFlagsContext(ctx context.Context, cmd memcachedCommand, routerHash, key, value, ttl []byte, flags uint32) (bool, error) {
+
+	stored, err := z.writeReplicas(ctx, routerHash, key, func(telnetConn *Telnet) replicaResult {
+		ok, err := z.baseStorage(telnetConn, cmd, key, value, ttl, flags)
+		return replicaResult{ok: ok, err: err}
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return stored >= z.configuration.writeQuorum(), nil
+}
+
+// ReplicatedDelete - deletes the key from its replicas, returns true if any replica has deleted the key and an error
+// if the write quorum has not answered
+func (z *Zencached) ReplicatedDelete(routerHash, key []byte) (bool, error) {
+
+	return z.ReplicatedDeleteContext(context.Background(), routerHash, key)
+}
+
+// ReplicatedDeleteContext - same as ReplicatedDelete, but using the context to cancel the operation
+func (z *Zencached) ReplicatedDeleteContext(ctx context.Context, routerHash, key []byte) (bool, error) {
+
+	deleted, err := z.writeReplicas(ctx, routerHash, key, func(telnetConn *Telnet) replicaResult {
+		ok, err := z.baseDelete(telnetConn, key)
+		return replicaResult{ok: ok, err: err}
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return deleted > 0, nil
+}
+
+// ReplicatedGet - reads the key from its replicas, returning as soon as the read quorum has answered
+// (a value found on any of them is returned) and an error if the read quorum can not be reached
+func (z *Zencached) ReplicatedGet(routerHash, key []byte) ([]byte, bool, error) {
+
+	return z.ReplicatedGetContext(context.Background(), routerHash, key)
+}
+
+// ReplicatedGetContext - same as ReplicatedGet, but using the context to cancel the operation
+func (z *Zencached) ReplicatedGetContext(ctx context.Context, routerHash, key []byte) ([]byte, bool, error) {
+
+	results, numReplicas, err := z.sendToReplicas(ctx, routerHash, key, func(telnetConn *Telnet) replicaResult {
+		value, found, err := z.baseGet(telnetConn, key)
+		return replicaResult{value: value, ok: found, err: err}
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	quorum := z.configuration.readQuorum()
+	answered := 0
+	var value []byte
+	var found bool
+	var errors []error
+
+	for i := 0; i < numReplicas && answered < quorum; i++ {
+
+		result := <-results
+		if result.err != nil {
+			errors = append(errors, result.err)
+			continue
+		}
+
+		answered++
+		if result.ok && !found {
+			value = result.value
+			found = true
+		}
+	}
+
+	if answered < quorum {
+		return nil, false, &QuorumError{
+			Required: quorum,
+			Reached:  answered,
+			Errors:   errors,
+		}
+	}
+
+	return value, found, nil
+}
@@ -0,0 +1,231 @@
+package zencached_test
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/uol/zencached"
+)
+
+// storeServer - a local server storing the values in memory
+type storeServer struct {
+	*scriptedServer
+	mutex  sync.Mutex
+	values map[string]string
+	flags  map[string]string
+}
+
+// value - returns the stored value
+func (s *storeServer) value(key string) (string, bool) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	value, ok := s.values[key]
+
+	return value, ok
+}
+
+// itemFlags - returns the stored client flags
+func (s *storeServer) itemFlags(key string) string {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.flags[key]
+}
+
+// setValue - stores the value directly
+func (s *storeServer) setValue(key, value string) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.values[key] = value
+	s.flags[key] = "0"
+}
+
+// createStoreServer - creates a local server answering the set, add, get and delete commands
+func createStoreServer() (*storeServer, zencached.Node) {
+
+	server := &storeServer{
+		values: map[string]string{},
+		flags:  map[string]string{},
+	}
+
+	var pendingCmd, pendingKey, pendingFlags string
+
+	scripted, node := createScriptedServer(func(conn net.Conn, connNumber int, line string) bool {
+
+		server.mutex.Lock()
+		defer server.mutex.Unlock()
+
+		fields := strings.Fields(line)
+
+		switch {
+		case len(fields) == 5 && (fields[0] == "set" || fields[0] == "add"):
+			pendingCmd, pendingKey, pendingFlags = fields[0], fields[1], fields[2]
+		case len(fields) == 2 && fields[0] == "get":
+			if value, ok := server.values[fields[1]]; ok {
+				conn.Write([]byte(fmt.Sprintf("VALUE %s %s %d\r\n%s\r\n", fields[1], server.flags[fields[1]], len(value), value)))
+			}
+			conn.Write([]byte("END\r\n"))
+		case len(fields) == 2 && fields[0] == "delete":
+			if _, ok := server.values[fields[1]]; ok {
+				delete(server.values, fields[1])
+				conn.Write([]byte("DELETED\r\n"))
+			} else {
+				conn.Write([]byte("NOT_FOUND\r\n"))
+			}
+		case pendingKey != "":
+			if _, ok := server.values[pendingKey]; ok && pendingCmd == "add" {
+				conn.Write([]byte("NOT_STORED\r\n"))
+			} else {
+				server.values[pendingKey] = line
+				server.flags[pendingKey] = pendingFlags
+				conn.Write([]byte("STORED\r\n"))
+			}
+			pendingKey = ""
+		default:
+			return false
+		}
+
+		return true
+	})
+
+	server.scriptedServer = scripted
+
+	return server, node
+}
+
+// withReplication - sets the replication factor and the quorums
+func withReplication(replicationFactor, writeQuorum, readQuorum int) configOption {
+
+	return func(c *zencached.Configuration) {
+		c.ReplicationFactor = replicationFactor
+		c.WriteQuorum = writeQuorum
+		c.ReadQuorum = readQuorum
+	}
+}
+
+// TestReplicatedOperations - tests if the replicated operations reach all replicas
+func TestReplicatedOperations(t *testing.T) {
+
+	servers := []*storeServer{}
+	nodes := []zencached.Node{}
+
+	for i := 0; i < 3; i++ {
+		server, node := createStoreServer()
+		defer server.listener.Close()
+
+		servers = append(servers, server)
+		nodes = append(nodes, node)
+	}
+
+	z, err := zencached.New(createLocalConfiguration(nodes, withRouting(zencached.KetamaRouting), withReplication(3, 2, 1)), nil)
+	if !assert.NoError(t, err, "unexpected error creating the client") {
+		return
+	}
+	defer z.Shutdown()
+
+	stored, err := z.ReplicatedStorage(zencached.Set, nil, []byte("key"), []byte("value"), defaultTTL)
+	if !assert.NoError(t, err, "unexpected error on replicated storage") || !assert.True(t, stored, "expected the value stored") {
+		return
+	}
+
+	for i, server := range servers {
+		value, ok := server.value("key")
+		assert.Truef(t, ok, "expected the value stored on node: %d", i)
+		assert.Equalf(t, "value", value, "expected the same value on node: %d", i)
+	}
+
+	value, found, err := z.ReplicatedGet(nil, []byte("key"))
+	if !assert.NoError(t, err, "unexpected error on replicated get") || !assert.True(t, found, "expected the value") {
+		return
+	}
+
+	assert.Equal(t, []byte("value"), value, "expected the stored value")
+
+	deleted, err := z.ReplicatedDelete(nil, []byte("key"))
+	if !assert.NoError(t, err, "unexpected error on replicated delete") || !assert.True(t, deleted, "expected the value deleted") {
+		return
+	}
+
+	for i, server := range servers {
+		_, ok := server.value("key")
+		assert.Falsef(t, ok, "expected the value deleted on node: %d", i)
+	}
+
+	stored, err = z.ReplicatedStorageWithFlags(zencached.Set, nil, []byte("flags"), []byte("value"), defaultTTL, 42)
+	if !assert.NoError(t, err, "unexpected error on replicated storage with flags") || !assert.True(t, stored, "expected the value stored") {
+		return
+	}
+
+	for i, server := range servers {
+		assert.Equalf(t, "42", server.itemFlags("flags"), "expected the flags stored on node: %d", i)
+	}
+}
+
+// TestReplicationQuorums - tests the quorums with a failed replica
+func TestReplicationQuorums(t *testing.T) {
+
+	server1, node1 := createStoreServer()
+	defer server1.listener.Close()
+
+	server2, node2 := createStoreServer()
+	defer server2.listener.Close()
+
+	nodes := []zencached.Node{node1, node2, createClosedNode()}
+
+	z, err := zencached.New(createLocalConfiguration(nodes, withRouting(zencached.KetamaRouting), withReplication(3, 2, 2)), nil)
+	if !assert.NoError(t, err, "unexpected error creating the client") {
+		return
+	}
+	defer z.Shutdown()
+
+	stored, err := z.ReplicatedStorage(zencached.Set, nil, []byte("key"), []byte("value"), defaultTTL)
+	if !assert.NoError(t, err, "expected the write quorum with a failed replica") || !assert.True(t, stored, "expected the value stored") {
+		return
+	}
+
+	value, found, err := z.ReplicatedGet(nil, []byte("key"))
+	if !assert.NoError(t, err, "expected the read quorum with a failed replica") || !assert.True(t, found, "expected the value") {
+		return
+	}
+
+	assert.Equal(t, []byte("value"), value, "expected the stored value")
+
+	z.Shutdown()
+
+	z, err = zencached.New(createLocalConfiguration(nodes, withRouting(zencached.KetamaRouting), withReplication(3, 3, 3)), nil)
+	if !assert.NoError(t, err, "unexpected error creating the client") {
+		return
+	}
+	defer z.Shutdown()
+
+	_, err = z.ReplicatedStorage(zencached.Set, nil, []byte("key"), []byte("value"), defaultTTL)
+	quorumErr, ok := err.(*zencached.QuorumError)
+	if assert.True(t, ok, "expected a quorum error on write") {
+		assert.Equal(t, 3, quorumErr.Required, "expected the write quorum")
+		assert.Equal(t, 2, quorumErr.Reached, "expected the live replicas")
+		assert.Len(t, quorumErr.Errors, 1, "expected the failed replica error")
+	}
+
+	_, _, err = z.ReplicatedGet(nil, []byte("key"))
+	_, ok = err.(*zencached.QuorumError)
+	assert.True(t, ok, "expected a quorum error on read")
+
+	_, err = zencached.New(createLocalConfiguration(nodes, withRouting(zencached.KetamaRouting), withReplication(3, 4, 1)), nil)
+	assert.Error(t, err, "expected an error with the write quorum greater than the replication factor")
+
+	_, err = zencached.New(createLocalConfiguration(nodes, withRouting(zencached.KetamaRouting), withReplication(4, 2, 1)), nil)
+	assert.Error(t, err, "expected an error with the replication factor greater than the number of nodes")
+
+	assert.Error(t, z.RemoveNode(node1), "expected an error removing a node required by the replication factor")
+	assert.Error(t, z.ReplaceNodes([]zencached.Node{node1, node2}), "expected an error replacing by fewer nodes than the replication factor")
+	assert.Len(t, z.Nodes(), 3, "expected the nodes unchanged")
+}
@@ -0,0 +1,357 @@
+package zencached
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/rand"
+	"net"
+	"sort"
+	"strconv"
+	"sync/atomic"
+)
+
+//
+// Pluggable routers used to distribute the keys among the nodes.
+//
+
+// RoutingAlgorithm - the algorithm used to route a key to a node
+type RoutingAlgorithm int
+
+const (
+	// ModuloRouting - uses the last byte of the key modulo the number of nodes (default)
+	ModuloRouting RoutingAlgorithm = iota
+
+	// KetamaRouting - uses a ketama compatible consistent hashing ring
+	KetamaRouting
+
+	// RendezvousRouting - uses the highest random weight (rendezvous) hashing
+	RendezvousRouting
+
+	// JumpHashRouting - uses the jump consistent hash
+	JumpHashRouting
+)
+
+// Router - routes a key to one of the configured nodes, implementations must be safe for concurrent use
+type Router interface {
+
+	// Route - returns the index of the node owning the key (the key is never empty)
+	Route(key []byte) int
+
+	// Rebuild - reconfigures the router with a new set of nodes
+	Rebuild(nodes []Node)
+}
+
+// ReplicaRouter - an optional Router extension choosing the nodes of the key replicas
+type ReplicaRouter interface {
+	Router
+
+	// RouteReplicas - returns up to n distinct node indexes for the key replicas, the first one is the index returned by Route
+	RouteReplicas(key []byte, n int) []int
+}
+
+// newRouter - creates the router based on the configuration (a custom router instance is rebuilt only once)
+func newRouter(configuration *Configuration, nodes []Node) Router {
+
+	var router Router
+
+	if configuration.Router != nil {
+		router = configuration.Router
+	} else {
+		router = configuration.newEmptyRouter()
+	}
+
+	router.Rebuild(nodes)
+
+	return router
+}
+
+// newEmptyRouter - creates a new router using the router factory or the routing algorithm
+func (c *Configuration) newEmptyRouter() Router {
+
+	if c.RouterFactory != nil {
+		return c.RouterFactory()
+	}
+
+	return newAlgorithmRouter(c.RoutingAlgorithm)
+}
+
+// checkRouter - returns an error if both custom routers are configured or the nodes of a router instance would be refreshed
+func (c *Configuration) checkRouter() error {
+
+	if c.Router != nil && c.RouterFactory != nil {
+		return fmt.Errorf("only one of the router or the router factory must be configured")
+	}
+
+	if c.Router != nil && c.Discoverer != nil && c.DiscoveryInterval > 0 {
+		return fmt.Errorf("the discovery refresh requires a router factory instead of a router instance")
+	}
+
+	return nil
+}
+
+// newAlgorithmRouter - creates an empty built-in router
+func newAlgorithmRouter(algorithm RoutingAlgorithm) Router {
+
+	switch algorithm {
+	case KetamaRouting:
+		return &KetamaRouter{}
+	case RendezvousRouting:
+		return &RendezvousRouter{}
+	case JumpHashRouting:
+		return &JumpHashRouter{}
+	default:
+		return &ModuloRouter{}
+	}
+}
+
+// nodeAddress - returns the node address in the "host:port" format ("[host]:port" for IPv6 hosts)
+func nodeAddress(node *Node) string {
+
+	return net.JoinHostPort(node.Host, strconv.Itoa(node.Port))
+}
+
+// hashKey - returns the 64 bits fnv-1a hash of the key
+func hashKey(key []byte) uint64 {
+
+	h := fnv.New64a()
+	h.Write(key)
+
+	return h.Sum64()
+}
+
+// ModuloRouter - routes using the last byte of the key modulo the number of weighted slots
+type ModuloRouter struct {
+	slots atomic.Value
+}
+
+// Route - returns the index of the node owning the key
+func (r *ModuloRouter) Route(key []byte) int {
+
+	slots := r.slots.Load().([]int)
+
+	return slots[int(key[len(key)-1])%len(slots)]
+}
+
+// Rebuild - reconfigures the router with a new set of nodes
+func (r *ModuloRouter) Rebuild(nodes []Node) {
+
+	r.slots.Store(interleavedSlots(nodes))
+}
+
+// KetamaRouter - routes using a ketama compatible consistent hashing ring
+type KetamaRouter struct {
+	ring atomic.Value
+}
+
+// Route - returns the index of the node owning the key
+func (r *KetamaRouter) Route(key []byte) int {
+
+	return r.ring.Load().(*ketamaRing).nodeIndex(key)
+}
+
+// RouteReplicas - returns the distinct nodes found walking the ring clockwise from the key
+func (r *KetamaRouter) RouteReplicas(key []byte, n int) []int {
+
+	return r.ring.Load().(*ketamaRing).nodeIndexes(key, n)
+}
+
+// Rebuild - reconfigures the router with a new set of nodes
+func (r *KetamaRouter) Rebuild(nodes []Node) {
+
+	r.ring.Store(newKetamaRing(nodes))
+}
+
+// RendezvousRouter - routes to the node with the highest weighted random score for the key
+type RendezvousRouter struct {
+	state atomic.Value
+}
+
+// rendezvousState - the node seeds and weights
+type rendezvousState struct {
+	seeds   []uint64
+	weights []float64
+}
+
+// Route - returns the index of the node owning the key
+func (r *RendezvousRouter) Route(key []byte) int {
+
+	state := r.state.Load().(*rendezvousState)
+	keyHash := hashKey(key)
+
+	index := 0
+	var maxScore float64
+
+	for i := 0; i < len(state.seeds); i++ {
+		score := weightedScore(mix64(state.seeds[i]^keyHash), state.weights[i])
+		if i == 0 || score > maxScore {
+			maxScore = score
+			index = i
+		}
+	}
+
+	return index
+}
+
+// RouteReplicas - returns the nodes with the n highest scores for the key
+func (r *RendezvousRouter) RouteReplicas(key []byte, n int) []int {
+
+	state := r.state.Load().(*rendezvousState)
+	keyHash := hashKey(key)
+
+	indexes := make([]int, len(state.seeds))
+	scores := make([]float64, len(state.seeds))
+
+	for i := 0; i < len(state.seeds); i++ {
+		indexes[i] = i
+		scores[i] = weightedScore(mix64(state.seeds[i]^keyHash), state.weights[i])
+	}
+
+	sort.SliceStable(indexes, func(a, b int) bool {
+		return scores[indexes[a]] > scores[indexes[b]]
+	})
+
+	if n < len(indexes) {
+		indexes = indexes[:n]
+	}
+
+	return indexes
+}
+
+// Rebuild - reconfigures the router with a new set of nodes
+func (r *RendezvousRouter) Rebuild(nodes []Node) {
+
+	state := &rendezvousState{
+		seeds:   make([]uint64, len(nodes)),
+		weights: make([]float64, len(nodes)),
+	}
+
+	for i := 0; i < len(nodes); i++ {
+		state.seeds[i] = hashKey([]byte(nodeAddress(&nodes[i])))
+		state.weights[i] = float64(nodes[i].weight())
+	}
+
+	r.state.Store(state)
+}
+
+// weightedScore - converts the hash to an uniform value in (0, 1) and applies the logarithmic weighting
+func weightedScore(hash uint64, weight float64) float64 {
+
+	uniform := (float64(hash>>11) + 0.5) / float64(uint64(1)<<53)
+
+	return -weight / math.Log(uniform)
+}
+
+// mix64 - the murmur3 64 bits finalizer
+func mix64(h uint64) uint64 {
+
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+
+	return h
+}
+
+// JumpHashRouter - routes using the jump consistent hash over the weighted slots (nodes must only be added or removed at the end of the list)
+type JumpHashRouter struct {
+	slots atomic.Value
+}
+
+// Route - returns the index of the node owning the key
+func (r *JumpHashRouter) Route(key []byte) int {
+
+	slots := r.slots.Load().([]int)
+
+	return slots[jumpHash(hashKey(key), len(slots))]
+}
+
+// Rebuild - reconfigures the router with a new set of nodes
+func (r *JumpHashRouter) Rebuild(nodes []Node) {
+
+	r.slots.Store(sequentialSlots(nodes))
+}
+
+// jumpHash - the Lamping and Veach jump consistent hash
+func jumpHash(key uint64, numBuckets int) int {
+
+	var b, j int64 = -1, 0
+
+	for j < int64(numBuckets) {
+		b = j
+		key = key*2862933555777941757 + 1
+		j = int64(float64(b+1) * (float64(int64(1)<<31) / float64((key>>33)+1)))
+	}
+
+	return int(b)
+}
+
+// interleavedSlots - distributes the node indexes in round robin over slots proportional to their reduced weights
+func interleavedSlots(nodes []Node) []int {
+
+	divisor, maxWeight := 0, 0
+
+	for i := 0; i < len(nodes); i++ {
+		divisor = gcd(divisor, nodes[i].weight())
+		if nodes[i].weight() > maxWeight {
+			maxWeight = nodes[i].weight()
+		}
+	}
+
+	slots := []int{}
+
+	for round := 0; round*divisor < maxWeight; round++ {
+		for i := 0; i < len(nodes); i++ {
+			if nodes[i].weight()/divisor > round {
+				slots = append(slots, i)
+			}
+		}
+	}
+
+	return slots
+}
+
+// sequentialSlots - distributes the node indexes over contiguous slots proportional to their weights
+func sequentialSlots(nodes []Node) []int {
+
+	slots := []int{}
+
+	for i := 0; i < len(nodes); i++ {
+		for j := 0; j < nodes[i].weight(); j++ {
+			slots = append(slots, i)
+		}
+	}
+
+	return slots
+}
+
+// gcd - the greatest common divisor
+func gcd(a, b int) int {
+
+	for b != 0 {
+		a, b = b, a%b
+	}
+
+	return a
+}
+
+// weightedRandomIndex - picks a random node index proportionally to its weight
+func weightedRandomIndex(nodes []Node) int {
+
+	totalWeight := 0
+	for i := 0; i < len(nodes); i++ {
+		totalWeight += nodes[i].weight()
+	}
+
+	n := rand.Intn(totalWeight)
+
+	for i := 0; i < len(nodes); i++ {
+		n -= nodes[i].weight()
+		if n < 0 {
+			return i
+		}
+	}
+
+	return len(nodes) - 1
+}
@@ -0,0 +1,245 @@
+package zencached_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/uol/zencached"
+)
+
+//
+// Routing tests, they do not require a running memcached.
+//
+
+// testRemapOnNodeAdded - tests if only the keys owned by the new node are remapped
+func testRemapOnNodeAdded(t *testing.T, algorithm zencached.RoutingAlgorithm) {
+
+	numKeys := 10000
+	keys := createKeys(numKeys)
+
+	before := routeKeys(createLocalZencached(createStaticNodes(4), nil, withRouting(algorithm)), keys)
+	after := routeKeys(createLocalZencached(createStaticNodes(5), nil, withRouting(algorithm)), keys)
+
+	remapped := 0
+	for i := 0; i < numKeys; i++ {
+		if before[i] != after[i] {
+			if !assert.Equalf(t, 4, after[i], "key remapped to an old node: %s", keys[i]) {
+				return
+			}
+			remapped++
+		}
+	}
+
+	assert.InDelta(t, float64(numKeys/5), float64(remapped), float64(numKeys)*0.05, "unexpected number of remapped keys")
+}
+
+// testDistribution - tests if the keys are distributed among all nodes
+func testDistribution(t *testing.T, algorithm zencached.RoutingAlgorithm) {
+
+	numKeys := 10000
+	numNodes := 4
+
+	z := createLocalZencached(createStaticNodes(numNodes), nil, withRouting(algorithm))
+
+	counters := make([]int, numNodes)
+	for _, index := range routeKeys(z, createKeys(numKeys)) {
+		counters[index]++
+	}
+
+	expected := float64(numKeys / numNodes)
+
+	for i := 0; i < numNodes; i++ {
+		assert.InDeltaf(t, expected, float64(counters[i]), expected*0.25, "unbalanced node: %d", i)
+	}
+}
+
+// TestModuloRouting - tests the default routing algorithm
+func TestModuloRouting(t *testing.T) {
+
+	z := createLocalZencached(createStaticNodes(3), nil, withRouting(zencached.ModuloRouting))
+
+	assert.Equal(t, []int{0, 2, 1, 0}, routeKeys(z, [][]byte{{0, 1, 2, 255}, {10, 199, 202, 149}, {206, 98, 60, 4}, {206, 98, 60, 3}}), "unexpected routing")
+}
+
+// TestRendezvousRouting - tests the rendezvous routing algorithm
+func TestRendezvousRouting(t *testing.T) {
+
+	testDistribution(t, zencached.RendezvousRouting)
+	testRemapOnNodeAdded(t, zencached.RendezvousRouting)
+}
+
+// TestJumpHashRouting - tests the jump hash routing algorithm
+func TestJumpHashRouting(t *testing.T) {
+
+	testDistribution(t, zencached.JumpHashRouting)
+	testRemapOnNodeAdded(t, zencached.JumpHashRouting)
+}
+
+// fixedRouter - routes every key to the same node
+type fixedRouter struct {
+	index    int
+	numNodes int
+}
+
+func (r *fixedRouter) Route(key []byte) int {
+	return r.index
+}
+
+func (r *fixedRouter) Rebuild(nodes []zencached.Node) {
+	r.numNodes = len(nodes)
+}
+
+// withRouter - sets the custom router instance
+func withRouter(router zencached.Router) configOption {
+
+	return func(c *zencached.Configuration) {
+		c.Router = router
+	}
+}
+
+// TestCustomRouter - tests if a custom router is used
+func TestCustomRouter(t *testing.T) {
+
+	router := &fixedRouter{index: 2}
+
+	z, err := zencached.New(createLocalConfiguration(createStaticNodes(3), withRouting(zencached.KetamaRouting), withRouter(router)), nil)
+	if !assert.NoError(t, err, "unexpected error creating the client") {
+		return
+	}
+
+	if !assert.Equal(t, 3, router.numNodes, "expected the router to be rebuilt") {
+		return
+	}
+
+	assert.Equal(t, []int{2, 2, 2}, routeKeys(z, createKeys(3)), "expected the custom routing")
+}
+
+// TestRouterFactory - tests if each node set has its own custom router and a router instance keeps its nodes
+func TestRouterFactory(t *testing.T) {
+
+	routers := []*fixedRouter{}
+
+	c := createLocalConfiguration(createStaticNodes(2), func(c *zencached.Configuration) {
+		c.RouterFactory = func() zencached.Router {
+			router := &fixedRouter{index: 1}
+			routers = append(routers, router)
+			return router
+		}
+	})
+
+	z, err := zencached.New(c, nil)
+	if !assert.NoError(t, err, "unexpected error creating the client") {
+		return
+	}
+	defer z.Shutdown()
+
+	first := routers[0]
+
+	if !assert.NoError(t, z.AddNode(createStaticNodes(3)[2]), "unexpected error adding a node") {
+		return
+	}
+
+	assert.Equal(t, 2, first.numNodes, "expected the router of the previous node set unchanged")
+	assert.Equal(t, []int{1, 1}, routeKeys(z, createKeys(2)), "expected the custom routing")
+
+	z, err = zencached.New(createLocalConfiguration(createStaticNodes(2), withRouter(&fixedRouter{index: 1})), nil)
+	if !assert.NoError(t, err, "unexpected error creating the client") {
+		return
+	}
+	defer z.Shutdown()
+
+	assert.Error(t, z.AddNode(createStaticNodes(3)[2]), "expected an error changing the nodes of a router instance")
+}
+
+// testWeightedDistribution - tests if the keys are distributed proportionally to the node weights
+func testWeightedDistribution(t *testing.T, algorithm zencached.RoutingAlgorithm) {
+
+	numKeys := 20000
+
+	nodes := createStaticNodes(3)
+	nodes[2].Weight = 2
+
+	z := createLocalZencached(nodes, nil, withRouting(algorithm))
+
+	counters := make([]int, len(nodes))
+	for _, index := range routeKeys(z, createKeys(numKeys)) {
+		counters[index]++
+	}
+
+	expected := []float64{float64(numKeys) * 0.25, float64(numKeys) * 0.25, float64(numKeys) * 0.5}
+
+	for i := 0; i < len(nodes); i++ {
+		assert.InDeltaf(t, expected[i], float64(counters[i]), expected[i]*0.2, "unexpected share for node %d using algorithm %d", i, algorithm)
+	}
+}
+
+// TestWeightedRouting - tests the node weights on all routing algorithms
+func TestWeightedRouting(t *testing.T) {
+
+	testWeightedDistribution(t, zencached.KetamaRouting)
+	testWeightedDistribution(t, zencached.RendezvousRouting)
+	testWeightedDistribution(t, zencached.JumpHashRouting)
+
+	nodes := createStaticNodes(2)
+	nodes[1].Weight = 3
+
+	z := createLocalZencached(nodes, nil, withRouting(zencached.ModuloRouting))
+
+	assert.Equal(t, []int{0, 1, 1, 1, 0}, routeKeys(z, [][]byte{{0}, {1}, {2}, {3}, {4}}), "unexpected weighted modulo routing")
+}
+
+// TestWeightedRandomRouting - tests the node weights when there is no key to route
+func TestWeightedRandomRouting(t *testing.T) {
+
+	numTries := 20000
+
+	nodes := createStaticNodes(2)
+	nodes[1].Weight = 3
+
+	z := createLocalZencached(nodes, nil, withRouting(zencached.ModuloRouting))
+
+	counters := make([]int, len(nodes))
+	for i := 0; i < numTries; i++ {
+		telnetConn, index := z.GetTelnetConnection(nil, nil)
+		z.ReturnTelnetConnection(telnetConn, index)
+		counters[index]++
+	}
+
+	assert.InDelta(t, float64(numTries)*0.75, float64(counters[1]), float64(numTries)*0.05, "unexpected share for the heavier node")
+}
+
+// testRouteReplicas - tests if the replicas are distinct nodes starting by the routed node
+func testRouteReplicas(t *testing.T, router zencached.ReplicaRouter) {
+
+	router.Rebuild(createStaticNodes(5))
+
+	for _, key := range createKeys(1000) {
+
+		replicas := router.RouteReplicas(key, 3)
+		if !assert.Len(t, replicas, 3, "expected three replicas") {
+			return
+		}
+
+		if !assert.Equal(t, router.Route(key), replicas[0], "expected the routed node as the first replica") {
+			return
+		}
+
+		if !assert.True(t, replicas[0] != replicas[1] && replicas[0] != replicas[2] && replicas[1] != replicas[2], "expected distinct replicas") {
+			return
+		}
+	}
+
+	assert.Len(t, router.RouteReplicas([]byte("key"), 10), 5, "expected the replicas limited by the number of nodes")
+}
+
+// TestKetamaReplicas - tests the ketama replicas
+func TestKetamaReplicas(t *testing.T) {
+
+	testRouteReplicas(t, &zencached.KetamaRouter{})
+}
+
+// TestRendezvousReplicas - tests the rendezvous replicas
+func TestRendezvousReplicas(t *testing.T) {
+
+	testRouteReplicas(t, &zencached.RendezvousRouter{})
+}
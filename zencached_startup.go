@@ -0,0 +1,129 @@
+package zencached
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/uol/logh"
+)
+
+//
+// The eager connection and validation of the nodes on startup.
+//
+
+// StartupPolicy - the policy used when a node fails on startup
+type StartupPolicy int
+
+const (
+	// FailFast - closes all connections and returns only the error (default)
+	FailFast StartupPolicy = iota
+
+	// StartDegraded - returns the instance without error, the failed nodes are returned by StartupError and reconnected on use
+	StartDegraded
+)
+
+// NodeFailure - a node failing the startup validation
+type NodeFailure struct {
+
+	// Node - the failed node
+	Node Node
+
+	// Err - the connection or validation error
+	Err error
+}
+
+// StartupError - lists all nodes failing the startup validation
+type StartupError struct {
+
+	// Failures - the failed nodes
+	Failures []NodeFailure
+}
+
+// Error - returns the error message with all failed nodes
+func (e *StartupError) Error() string {
+
+	failures := make([]string, len(e.Failures))
+	for i := 0; i < len(e.Failures); i++ {
+		failures[i] = nodeAddress(&e.Failures[i].Node) + " (" + e.Failures[i].Err.Error() + ")"
+	}
+
+	return "error connecting to nodes: " + strings.Join(failures, ", ")
+}
+
+// StartupError - returns the nodes failing the eager connection of an instance started degraded (nil if all nodes were connected)
+func (z *Zencached) StartupError() *StartupError {
+
+	return z.startupErr
+}
+
+// connectAll - dials all nodes concurrently and verifies each node, the failures keep the node order
+func (z *Zencached) connectAll() *StartupError {
+
+	pools := z.loadNodes().pools
+	errs := make([]error, len(pools))
+
+	var wg sync.WaitGroup
+	wg.Add(len(pools))
+
+	for i := 0; i < len(pools); i++ {
+
+		go func(i int) {
+
+			defer wg.Done()
+
+			errs[i] = z.connectNode(pools[i])
+		}(i)
+	}
+
+	wg.Wait()
+
+	var failures []NodeFailure
+
+	for i, err := range errs {
+
+		if err != nil {
+			if logh.ErrorEnabled {
+				z.logger.Error().Err(err).Msgf("error connecting to node: %s", nodeAddress(&pools[i].node))
+			}
+
+			failures = append(failures, NodeFailure{
+				Node: pools[i].node,
+				Err:  err,
+			})
+		}
+	}
+
+	if len(failures) == 0 {
+		return nil
+	}
+
+	return &StartupError{
+		Failures: failures,
+	}
+}
+
+// connectNode - dials all connections of the node, verifying the first one (stops on the first error)
+func (z *Zencached) connectNode(pool *nodePool) error {
+
+	for c := 0; c < z.configuration.NumConnectionsPerNode; c++ {
+
+		telnetConn := <-pool.conns
+
+		err := telnetConn.Connect()
+		if err == nil && c == 0 {
+			err = z.checkTelnetConnection(telnetConn)
+		}
+
+		if err != nil {
+			telnetConn.markBroken()
+		}
+
+		z.ReturnTelnetConnection(telnetConn, -1)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
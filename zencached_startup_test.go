@@ -0,0 +1,151 @@
+package zencached_test
+
+import (
+	"net"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/uol/zencached"
+)
+
+// createVersionServer - creates a local server answering only the version command
+func createVersionServer() (*scriptedServer, zencached.Node) {
+
+	return createScriptedServer(func(conn net.Conn, connNumber int, line string) bool {
+
+		if line != "version" {
+			return false
+		}
+
+		conn.Write([]byte("VERSION 1.6.0\r\n"))
+
+		return true
+	})
+}
+
+// withEagerConnect - connects all the connections on startup using the policy
+func withEagerConnect(policy zencached.StartupPolicy) configOption {
+
+	return func(c *zencached.Configuration) {
+		c.NumConnectionsPerNode = 3
+		c.EagerConnect = true
+		c.StartupPolicy = policy
+	}
+}
+
+// TestEagerConnect - tests if all connections are dialed and verified on startup
+func TestEagerConnect(t *testing.T) {
+
+	server, node := createVersionServer()
+	defer server.listener.Close()
+
+	z, err := zencached.New(createLocalConfiguration([]zencached.Node{node}, withEagerConnect(zencached.FailFast)), nil)
+	if !assert.NoError(t, err, "unexpected error on startup") {
+		return
+	}
+
+	defer z.Shutdown()
+
+	<-time.After(50 * time.Millisecond)
+
+	assert.Equal(t, uint32(3), atomic.LoadUint32(&server.connections), "expected all connections to be dialed")
+	assert.Equal(t, uint32(1), atomic.LoadUint32(&server.commands), "expected the node to be verified")
+}
+
+// TestEagerConnectFailFast - tests if no instance is returned when a node fails
+func TestEagerConnectFailFast(t *testing.T) {
+
+	server, node := createVersionServer()
+	defer server.listener.Close()
+
+	closedNode := createClosedNode()
+
+	z, err := zencached.New(createLocalConfiguration([]zencached.Node{node, closedNode}, withEagerConnect(zencached.FailFast)), nil)
+	assert.Nil(t, z, "expected no instance")
+
+	startupErr, ok := err.(*zencached.StartupError)
+	if !assert.True(t, ok, "expected a startup error") {
+		return
+	}
+
+	if assert.Len(t, startupErr.Failures, 1, "expected only one failed node") {
+		assert.Equal(t, closedNode, startupErr.Failures[0].Node, "expected the closed node")
+		assert.Error(t, startupErr.Failures[0].Err, "expected the connection error")
+	}
+
+	assert.True(t, strings.Contains(err.Error(), closedNode.Host), "expected the node in the error message")
+}
+
+// TestEagerConnectStartDegraded - tests if the instance is returned along with the failed nodes
+func TestEagerConnectStartDegraded(t *testing.T) {
+
+	server, node := createVersionServer()
+	defer server.listener.Close()
+
+	invalidServer, invalidNode := createScriptedServer(func(conn net.Conn, connNumber int, line string) bool {
+
+		conn.Write([]byte("ERROR\r\n"))
+
+		return true
+	})
+	defer invalidServer.listener.Close()
+
+	closedNode := createClosedNode()
+
+	z, err := zencached.New(createLocalConfiguration([]zencached.Node{closedNode, node, invalidNode}, withEagerConnect(zencached.StartDegraded)), nil)
+	if !assert.NoError(t, err, "expected no error starting degraded") || !assert.NotNil(t, z, "expected the instance") {
+		return
+	}
+
+	defer z.Shutdown()
+
+	startupErr := z.StartupError()
+	if !assert.NotNil(t, startupErr, "expected a startup error") {
+		return
+	}
+
+	if assert.Len(t, startupErr.Failures, 2, "expected two failed nodes") {
+		assert.Equal(t, closedNode, startupErr.Failures[0].Node, "expected the closed node")
+		assert.Equal(t, invalidNode, startupErr.Failures[1].Node, "expected the invalid node")
+	}
+
+	// the connections are counted asynchronously by the server
+	<-time.After(50 * time.Millisecond)
+
+	assert.Equal(t, uint32(3), atomic.LoadUint32(&server.connections), "expected the valid node to be connected")
+}
+
+// TestEagerConnectConcurrently - tests if the nodes are dialed and verified concurrently
+func TestEagerConnectConcurrently(t *testing.T) {
+
+	nodes := make([]zencached.Node, 3)
+
+	for i := 0; i < len(nodes); i++ {
+
+		var server *scriptedServer
+		server, nodes[i] = createScriptedServer(func(conn net.Conn, connNumber int, line string) bool {
+
+			<-time.After(200 * time.Millisecond)
+
+			conn.Write([]byte("VERSION 1.6.0\r\n"))
+
+			return true
+		})
+		defer server.listener.Close()
+	}
+
+	start := time.Now()
+
+	z, err := zencached.New(createLocalConfiguration(nodes, withEagerConnect(zencached.FailFast)), nil)
+	if !assert.NoError(t, err, "unexpected error on startup") {
+		return
+	}
+
+	defer z.Shutdown()
+
+	assert.True(t, time.Since(start) < 400*time.Millisecond, "expected the nodes to be verified concurrently")
+	assert.Nil(t, z.StartupError(), "expected no failed nodes")
+}
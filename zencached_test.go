@@ -3,6 +3,7 @@ package zencached_test
 import (
 	"fmt"
 	"regexp"
+	"sync"
 	"testing"
 	"time"
 
@@ -186,6 +187,95 @@ func TestGetCommand(t *testing.T) {
 	f([]byte{8}, "test7", "test8", 9)
 }
 
+// TestBinaryValues - tests if binary values are stored and retrieved byte by byte
+func TestBinaryValues(t *testing.T) {
+
+	z := createZencached(nil)
+	defer z.Shutdown()
+
+	allBytes := make([]byte, 256)
+	for i := 0; i < len(allBytes); i++ {
+		allBytes[i] = byte(i)
+	}
+
+	large := make([]byte, 100000)
+	for i := 0; i < len(large); i++ {
+		large[i] = byte(i % 251)
+	}
+
+	f := func(key string, value []byte, testIndex int) {
+
+		stored, err := z.Storage(zencached.Set, nil, []byte(key), value, defaultTTL)
+		if !assert.NoErrorf(t, err, "unexpected error storing on test %d", testIndex) {
+			return
+		}
+
+		if !assert.Truef(t, stored, "expected the value to be stored on test %d", testIndex) {
+			return
+		}
+
+		storedValue, found, err := z.Get(nil, []byte(key))
+		if !assert.NoErrorf(t, err, "unexpected error reading on test %d", testIndex) {
+			return
+		}
+
+		if !assert.Truef(t, found, "expected the value to be found on test %d", testIndex) {
+			return
+		}
+
+		assert.Equalf(t, value, storedValue, "expected the same bytes on test %d", testIndex)
+	}
+
+	f("binary1", allBytes, 1)
+	f("binary2", []byte("\r\nEND\r\n"), 2)
+	f("binary3", []byte("STORED\r\nVALUE binary3 0 1\r\n"), 3)
+	f("binary4", []byte{}, 4)
+	f("binary5", large, 5)
+}
+
+// TestGetMultiCommand - tests the multi key get command
+func TestGetMultiCommand(t *testing.T) {
+
+	z := createZencached(nil)
+	defer z.Shutdown()
+
+	keys := [][]byte{}
+	expected := map[string][]byte{}
+
+	for i := 0; i < 20; i++ {
+
+		key := []byte(fmt.Sprintf("multi%d", i))
+		keys = append(keys, key)
+
+		if i%4 == 0 {
+			_, err := z.Delete(nil, key)
+			if err != nil {
+				panic(err)
+			}
+			continue
+		}
+
+		value := []byte(fmt.Sprintf("value%d\r\nEND\r\n", i))
+		expected[string(key)] = value
+
+		stored, err := z.Storage(zencached.Set, nil, key, value, defaultTTL)
+		if err != nil {
+			panic(err)
+		}
+
+		if !assert.Truef(t, stored, "expected key %s to be stored", key) {
+			return
+		}
+	}
+
+	values, err := z.GetMulti(nil, keys)
+	if !assert.NoError(t, err, "unexpected error on multi get") {
+		return
+	}
+
+	assert.Equal(t, expected, values, "expected the same values")
+}
+
 // TestSetCommand - tests the set command
 func TestSetCommand(t *testing.T) {
 
@@ -223,6 +313,100 @@ func TestSetCommand(t *testing.T) {
 	f([]byte{8}, "test7", "test8", 9)
 }
 
+// TestReplaceAppendPrependCommands - tests the replace, append and prepend commands
+func TestReplaceAppendPrependCommands(t *testing.T) {
+
+	z := createZencached(nil)
+	defer z.Shutdown()
+
+	key := []byte("activity")
+
+	f := func(cmd []byte, value string, expectedStored bool, expectedValue string, testIndex int) {
+
+		stored, err := z.Storage(cmd, nil, key, []byte(value), defaultTTL)
+		if !assert.NoErrorf(t, err, "unexpected error on test %d", testIndex) {
+			return
+		}
+
+		if !assert.Equalf(t, expectedStored, stored, "unexpected storage status for test %d", testIndex) {
+			return
+		}
+
+		storedValue, found, err := z.Get(nil, key)
+		if err != nil {
+			panic(err)
+		}
+
+		if expectedValue == "" {
+			assert.Falsef(t, found, "expected no value for test %d", testIndex)
+			return
+		}
+
+		assert.Equalf(t, []byte(expectedValue), storedValue, "unexpected value for test %d", testIndex)
+	}
+
+	_, err := z.Delete(nil, key)
+	if err != nil {
+		panic(err)
+	}
+
+	f(zencached.Replace, "b", false, "", 1)
+	f(zencached.Append, "c", false, "", 2)
+	f(zencached.Prepend, "a", false, "", 3)
+	f(zencached.Set, "b", true, "b", 4)
+	f(zencached.Append, "c", true, "bc", 5)
+	f(zencached.Prepend, "a", true, "abc", 6)
+	f(zencached.Replace, "x", true, "x", 7)
+
+	_, err = z.Storage([]byte("get"), nil, key, []byte("y"), defaultTTL)
+	assert.Error(t, err, "expected an error using a non storage command")
+}
+
+// TestItemFlags - tests storing and retrieving the client flags
+func TestItemFlags(t *testing.T) {
+
+	z := createZencached(nil)
+	defer z.Shutdown()
+
+	key := []byte("flags")
+	value := []byte("flags-value")
+
+	f := func(flags uint32, testIndex int) {
+
+		stored, err := z.StorageWithFlags(zencached.Set, nil, key, value, defaultTTL, flags)
+		if !assert.NoErrorf(t, err, "unexpected error on test %d", testIndex) {
+			return
+		}
+
+		if !assert.Truef(t, stored, "expected the key to be stored on test %d", testIndex) {
+			return
+		}
+
+		item, found, err := z.GetItem(nil, key)
+		if !assert.NoErrorf(t, err, "unexpected error on test %d", testIndex) {
+			return
+		}
+
+		if !assert.Truef(t, found, "expected the key to be found on test %d", testIndex) {
+			return
+		}
+
+		assert.Equalf(t, value, item.Value, "expected the same value on test %d", testIndex)
+		assert.Equalf(t, flags, item.Flags, "expected the same flags on test %d", testIndex)
+
+		item, _, err = z.Gets(nil, key)
+		if !assert.NoErrorf(t, err, "unexpected error on test %d", testIndex) {
+			return
+		}
+
+		assert.Equalf(t, flags, item.Flags, "expected the same flags using gets on test %d", testIndex)
+	}
+
+	f(0, 1)
+	f(1, 2)
+	f(4294967295, 3)
+}
+
 // TestDeleteCommand - tests the delete command
 func TestDeleteCommand(t *testing.T) {
 
@@ -270,18 +454,246 @@ func TestDeleteCommand(t *testing.T) {
 	f([]byte{8}, "test7", "test8", false, 9)
 }
 
+// TestCompareAndSwapCommand - tests the gets and cas commands
+func TestCompareAndSwapCommand(t *testing.T) {
+
+	z := createZencached(nil)
+	defer z.Shutdown()
+
+	key := []byte("cas")
+
+	_, err := z.Storage(zencached.Set, nil, key, []byte("v1"), defaultTTL)
+	if err != nil {
+		panic(err)
+	}
+
+	item, found, err := z.Gets(nil, key)
+	if !assert.NoError(t, err, "unexpected error on gets") {
+		return
+	}
+
+	if !assert.True(t, found, "expected the item to be found") {
+		return
+	}
+
+	assert.Equal(t, []byte("v1"), item.Value, "expected the same value")
+	assert.NotEqual(t, uint64(0), item.CAS, "expected a cas unique")
+
+	f := func(item *zencached.Item, expected zencached.CASResult, testIndex int) {
+
+		result, err := z.CompareAndSwap(nil, item, defaultTTL)
+		if !assert.NoErrorf(t, err, "unexpected error on cas for test %d", testIndex) {
+			return
+		}
+
+		assert.Equalf(t, expected, result, "unexpected cas result for test %d", testIndex)
+	}
+
+	f(&zencached.Item{Key: key, Value: []byte("v2"), CAS: item.CAS}, zencached.CASStored, 1)
+	f(&zencached.Item{Key: key, Value: []byte("v3"), CAS: item.CAS}, zencached.CASExists, 2)
+
+	value, _, err := z.Get(nil, key)
+	if err != nil {
+		panic(err)
+	}
+
+	assert.Equal(t, []byte("v2"), value, "expected the swapped value")
+
+	_, err = z.Delete(nil, key)
+	if err != nil {
+		panic(err)
+	}
+
+	f(&zencached.Item{Key: key, Value: []byte("v4"), CAS: item.CAS}, zencached.CASNotFound, 3)
+
+	_, found, err = z.Gets(nil, key)
+	if !assert.NoError(t, err, "unexpected error on gets") {
+		return
+	}
+
+	assert.False(t, found, "expected the item to not be found")
+}
+
+// TestArithmeticCommands - tests the incr and decr commands
+func TestArithmeticCommands(t *testing.T) {
+
+	z := createZencached(nil)
+	defer z.Shutdown()
+
+	key := []byte("counter")
+
+	_, err := z.Delete(nil, key)
+	if err != nil {
+		panic(err)
+	}
+
+	_, found, err := z.Increment(nil, key, 1)
+	if !assert.NoError(t, err, "unexpected error on incr") {
+		return
+	}
+
+	if !assert.False(t, found, "expected the key to not be found") {
+		return
+	}
+
+	value, err := z.IncrementWithSeed(nil, key, 1, 10, defaultTTL)
+	if !assert.NoError(t, err, "unexpected error on incr with seed") {
+		return
+	}
+
+	assert.Equal(t, uint64(10), value, "expected the seed value")
+
+	f := func(increment bool, delta, expected uint64, testIndex int) {
+
+		var value uint64
+		var found bool
+		var err error
+
+		if increment {
+			value, found, err = z.Increment(nil, key, delta)
+		} else {
+			value, found, err = z.Decrement(nil, key, delta)
+		}
+
+		if !assert.NoErrorf(t, err, "unexpected error on test %d", testIndex) {
+			return
+		}
+
+		if !assert.Truef(t, found, "expected the key to be found on test %d", testIndex) {
+			return
+		}
+
+		assert.Equalf(t, expected, value, "unexpected value on test %d", testIndex)
+	}
+
+	f(true, 5, 15, 1)
+	f(false, 3, 12, 2)
+	f(false, 20, 0, 3)
+	f(true, 18446744073709551615, 18446744073709551615, 4)
+
+	value, err = z.DecrementWithSeed(nil, key, 1, 10, defaultTTL)
+	if !assert.NoError(t, err, "unexpected error on decr with seed") {
+		return
+	}
+
+	assert.Equal(t, uint64(18446744073709551614), value, "expected the decremented value")
+}
+
+// TestTouchCommands - tests the touch, gat and gats commands
+func TestTouchCommands(t *testing.T) {
+
+	z := createZencached(nil)
+	defer z.Shutdown()
+
+	key := []byte("touch")
+	value := []byte("touch-value")
+
+	_, err := z.Delete(nil, key)
+	if err != nil {
+		panic(err)
+	}
+
+	touched, err := z.Touch(nil, key, defaultTTL)
+	if !assert.NoError(t, err, "unexpected error on touch") {
+		return
+	}
+
+	if !assert.False(t, touched, "expected the key to not be touched") {
+		return
+	}
+
+	_, err = z.Storage(zencached.Set, nil, key, value, []byte("1"))
+	if err != nil {
+		panic(err)
+	}
+
+	touched, err = z.Touch(nil, key, []byte("3"))
+	if !assert.NoError(t, err, "unexpected error on touch") {
+		return
+	}
+
+	if !assert.True(t, touched, "expected the key to be touched") {
+		return
+	}
+
+	storedValue, found, err := z.GetAndTouch(nil, key, defaultTTL)
+	if !assert.NoError(t, err, "unexpected error on gat") {
+		return
+	}
+
+	if !assert.True(t, found, "expected the key to be found") {
+		return
+	}
+
+	assert.Equal(t, value, storedValue, "expected the same value")
+
+	<-time.After(1500 * time.Millisecond)
+
+	item, found, err := z.GetsAndTouch(nil, key, defaultTTL)
+	if !assert.NoError(t, err, "unexpected error on gats") {
+		return
+	}
+
+	if !assert.True(t, found, "expected the key to be found after its original ttl") {
+		return
+	}
+
+	assert.Equal(t, value, item.Value, "expected the same value")
+	assert.NotEqual(t, uint64(0), item.CAS, "expected a cas unique")
+}
+
+// testCollector - collects the metrics (safe for concurrent use)
 type testCollector struct {
+	mutex     sync.Mutex
 	collected []string
+	counts    map[string][][]interface{}
 }
 
 func (c *testCollector) Count(value float64, metric string, tags ...interface{}) {
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
 	c.collected = append(c.collected, fmt.Sprintf("count/%s/%f/%v", metric, value, tags))
+
+	if c.counts == nil {
+		c.counts = map[string][][]interface{}{}
+	}
+
+	c.counts[metric] = append(c.counts[metric], tags)
 }
 
 func (c *testCollector) Maximum(value float64, metric string, tags ...interface{}) {
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
 	c.collected = append(c.collected, fmt.Sprintf("max/%s/%f/%v", metric, value, tags))
 }
 
+// count - returns the number of times the metric was counted
+func (c *testCollector) count(metric string) int {
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return len(c.counts[metric])
+}
+
+// tagValues - returns the tag value at the index of each count of the metric
+func (c *testCollector) tagValues(metric string, index int) []string {
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	values := []string{}
+	for _, tags := range c.counts[metric] {
+		values = append(values, fmt.Sprint(tags[index]))
+	}
+
+	return values
+}
+
 // TestMetricsCollector - tests the metrics collector interface
 func TestMetricsCollector(t *testing.T) {
 